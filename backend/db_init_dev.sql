@@ -16,6 +16,7 @@ ALTER DEFAULT PRIVILEGES FOR ROLE postgres IN SCHEMA public GRANT SELECT, INSERT
 
 -- Import necessary extensions
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
 
 -- Enums
 CREATE TYPE STATUS AS ENUM (
@@ -29,6 +30,12 @@ CREATE TYPE VOTE AS ENUM (
     'Dislike'
 );
 
+CREATE TYPE WEBHOOK_DELIVERY_STATUS AS ENUM (
+    'Pending',
+    'Delivered',
+    'Failed'
+);
+
 -- Tables
 CREATE TABLE IF NOT EXISTS user_account (
     username VARCHAR(20) PRIMARY KEY,
@@ -45,6 +52,17 @@ CREATE TABLE IF NOT EXISTS post (
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
 
+    -- Incremented on every edit. Updates must provide the version they were based on (optimistic concurrency control)
+    version INT NOT NULL DEFAULT 1,
+
+    -- Vote counters maintained by the backend whenever a vote is cast or removed
+    -- Run "backend reconcile-vote-counters" to repair them if they drift from the post_vote table
+    likes INT NOT NULL DEFAULT 0,
+    dislikes INT NOT NULL DEFAULT 0,
+
+    -- Time-decayed popularity used by the "Hot" sort. It is refreshed periodically by the backend
+    hot_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+
     -- Calculate the vector embeddings of the title and body combined to enable search by query
     textsearchable_index tsvector GENERATED ALWAYS AS (to_tsvector('english', title || ' ' || body)) STORED,
 
@@ -54,28 +72,140 @@ CREATE TABLE IF NOT EXISTS post (
 -- Create a GIN index of the vector embeddings to speed up search
 CREATE INDEX textsearch_post_idx ON post USING GIN (textsearchable_index);
 
+-- Create a trigram index of the titles to enable typo-tolerant search and autocomplete of titles
+CREATE INDEX post_title_trgm_idx ON post USING GIN (title gin_trgm_ops);
+
+-- Create an index of the hot scores to speed up sorting by "Hot"
+CREATE INDEX post_hot_score_idx ON post (hot_score DESC);
+
+-- The tag catalogue. Tag names are unique case-insensitively
+-- Retired tags are kept on existing posts but can no longer be added to posts
+-- Posts with a tag are also listed under its parent tag (and the parent's ancestors)
+CREATE TABLE IF NOT EXISTS tag (
+    name VARCHAR(30) PRIMARY KEY,
+    description VARCHAR(500) NOT NULL DEFAULT '',
+    colour VARCHAR(7) NOT NULL DEFAULT '#9e9e9e',
+    retired BOOLEAN NOT NULL DEFAULT false,
+    parent VARCHAR(30),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (parent) REFERENCES tag(name) ON UPDATE CASCADE,
+    CHECK (parent <> name)
+);
+
+CREATE UNIQUE INDEX tag_name_lower_idx ON tag (lower(name));
+CREATE INDEX tag_parent_idx ON tag (parent);
+
+-- Synonyms of tags, which are replaced by their tag when they are added to posts
+-- Aliases are unique case-insensitively, and the backend ensures that they are not the names of tags
+CREATE TABLE IF NOT EXISTS tag_alias (
+    alias VARCHAR(30) PRIMARY KEY,
+    tag VARCHAR(30) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (tag) REFERENCES tag(name) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX tag_alias_lower_idx ON tag_alias (lower(alias));
+CREATE INDEX tag_alias_tag_idx ON tag_alias (tag);
+
+-- Renaming a tag is cascaded to its posts
 CREATE TABLE IF NOT EXISTS post_tag (
     post_id UUID,
     tag VARCHAR(30),
     
     PRIMARY KEY (post_id, tag),
-    FOREIGN KEY (post_id) REFERENCES post(id)
+    FOREIGN KEY (post_id) REFERENCES post(id),
+    FOREIGN KEY (tag) REFERENCES tag(name) ON UPDATE CASCADE
 );
 
+-- Create an index of the tags to speed up counting the posts of each tag and cascading renames
+CREATE INDEX post_tag_tag_idx ON post_tag (tag);
+
 -- Create an index with the tags lowercased to make case-insensitive search by
 -- tags more efficient
 CREATE INDEX tag_lower_index ON post_tag (lower(tag));
 
+-- Create a trigram index of the lowercased tags to enable typo-tolerant search and autocomplete of tags
+CREATE INDEX tag_lower_trgm_idx ON post_tag USING GIN (lower(tag) gin_trgm_ops);
+
 CREATE TABLE IF NOT EXISTS post_vote (
     viewer VARCHAR(20),
     post_id UUID,
     vote VOTE NOT NULL,
+    voted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
 
     PRIMARY KEY (viewer, post_id),
     FOREIGN KEY (viewer) REFERENCES user_account(username),
     FOREIGN KEY (post_id) REFERENCES post(id)
 );
 
+-- Bookmarks are private to the viewer and do not affect a post's popularity
+CREATE TABLE IF NOT EXISTS post_bookmark (
+    viewer VARCHAR(20),
+    post_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (viewer, post_id),
+    FOREIGN KEY (viewer) REFERENCES user_account(username),
+    FOREIGN KEY (post_id) REFERENCES post(id)
+);
+
+-- Users can follow other users and tags to build their feed
+CREATE TABLE IF NOT EXISTS user_follow (
+    follower VARCHAR(20),
+    followee VARCHAR(20),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (follower, followee),
+    FOREIGN KEY (follower) REFERENCES user_account(username),
+    FOREIGN KEY (followee) REFERENCES user_account(username),
+    CHECK (follower <> followee)
+);
+
+-- Create an index of the followees to make counting followers more efficient
+CREATE INDEX user_follow_followee_idx ON user_follow (followee);
+
+CREATE TABLE IF NOT EXISTS tag_follow (
+    follower VARCHAR(20),
+    tag VARCHAR(30),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (follower, tag),
+    FOREIGN KEY (follower) REFERENCES user_account(username)
+);
+
+-- Tags are followed case-insensitively
+CREATE UNIQUE INDEX tag_follow_lower_idx ON tag_follow (follower, lower(tag));
+CREATE INDEX tag_follow_tag_lower_idx ON tag_follow (lower(tag));
+
+-- Users can block other users to stop seeing their posts and comments, and to stop them from replying to their comments
+CREATE TABLE IF NOT EXISTS user_block (
+    blocker VARCHAR(20),
+    blocked VARCHAR(20),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (blocker, blocked),
+    FOREIGN KEY (blocker) REFERENCES user_account(username),
+    FOREIGN KEY (blocked) REFERENCES user_account(username),
+    CHECK (blocker <> blocked)
+);
+
+-- Bans issued by moderators. A user has at most one ban at a time. A ban without an expiry is permanent
+-- "Ban" stops the user from making changes. "ShadowBan" hides their posts and comments from everyone else
+CREATE TABLE IF NOT EXISTS user_ban (
+    username VARCHAR(20) PRIMARY KEY,
+    type VARCHAR(10) NOT NULL CHECK (type IN ('Ban', 'ShadowBan')),
+    reason VARCHAR(500) NOT NULL,
+    issued_by VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (username) REFERENCES user_account(username),
+    FOREIGN KEY (issued_by) REFERENCES user_account(username)
+);
+
 CREATE TABLE IF NOT EXISTS comment (
     id UUID PRIMARY KEY,
     body VARCHAR(10000) NOT NULL,
@@ -88,6 +218,13 @@ CREATE TABLE IF NOT EXISTS comment (
     created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
     updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
 
+    -- Incremented on every edit. Updates must provide the version they were based on (optimistic concurrency control)
+    version INT NOT NULL DEFAULT 1,
+
+    -- Vote counters maintained by the backend whenever a vote is cast or removed
+    likes INT NOT NULL DEFAULT 0,
+    dislikes INT NOT NULL DEFAULT 0,
+
     -- Calculate the vector embeddings of the title and body combined to enable search by query
     textsearchable_index tsvector GENERATED ALWAYS AS (to_tsvector('english', body)) STORED,
 
@@ -109,6 +246,51 @@ CREATE TABLE IF NOT EXISTS comment_vote (
     FOREIGN KEY (comment_id) REFERENCES comment(id)
 );
 
+-- Webhook subscriptions. A webhook without tags receives events of all tags
+CREATE TABLE IF NOT EXISTS webhook (
+    id UUID PRIMARY KEY,
+    target_url VARCHAR(2000) NOT NULL,
+    secret VARCHAR(200) NOT NULL,
+    event_types VARCHAR(30)[] NOT NULL,
+    tags VARCHAR(30)[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_by VARCHAR(20) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (created_by) REFERENCES user_account(username)
+);
+
+-- Outbox of webhook deliveries. Rows are added when an event occurs and sent by the webhook dispatcher
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id UUID NOT NULL,
+    event_type VARCHAR(30) NOT NULL,
+    payload JSONB NOT NULL,
+    status WEBHOOK_DELIVERY_STATUS NOT NULL DEFAULT 'Pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (webhook_id) REFERENCES webhook(id) ON DELETE CASCADE
+);
+
+-- Create a partial index of the pending deliveries so the dispatcher can find due deliveries quickly
+CREATE INDEX webhook_delivery_pending_idx ON webhook_delivery (next_attempt_at) WHERE status = 'Pending';
+
+-- Log of every attempt to send a webhook delivery
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempt (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    delivery_id UUID NOT NULL,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    response_status INT,
+    error_message TEXT,
+    duration_ms BIGINT NOT NULL,
+
+    FOREIGN KEY (delivery_id) REFERENCES webhook_delivery(id) ON DELETE CASCADE
+);
+
 -- Authorization Rule table
 CREATE TABLE IF NOT EXISTS casbin_rule (
     ID UUID PRIMARY KEY NOT NULL DEFAULT gen_random_uuid(),
@@ -125,6 +307,35 @@ CREATE TABLE IF NOT EXISTS casbin_rule (
     UNIQUE NULLS NOT DISTINCT (Ptype, V0, V1, V2, V3, V4, V5)
 );
 
+-- Log of security and moderation events, e.g. logins, bans and soft deletes. The backend can only append to it
+-- The actor is empty if nobody was signed in. The target id is empty if the target has no id (e.g. the settings)
+CREATE TABLE IF NOT EXISTS audit_event (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor VARCHAR(20) NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(20) NOT NULL,
+    target_id VARCHAR(300) NOT NULL DEFAULT '',
+    ip VARCHAR(100) NOT NULL DEFAULT '',
+    request_id VARCHAR(36) NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+REVOKE UPDATE, DELETE, TRUNCATE ON audit_event FROM backend;
+
+CREATE INDEX audit_event_created_at_idx ON audit_event (created_at DESC);
+CREATE INDEX audit_event_actor_idx ON audit_event (actor, created_at DESC);
+CREATE INDEX audit_event_target_idx ON audit_event (target_type, target_id, created_at DESC);
+
+-- App-wide settings that admins can change at runtime. The table has exactly one row
+CREATE TABLE IF NOT EXISTS app_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    allow_freeform_tags BOOLEAN NOT NULL DEFAULT true,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO app_settings DEFAULT VALUES;
+
 --- Authorization Rules for non-logged in users
 INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/session', 'POST');
 INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/session', 'DELETE');
@@ -132,7 +343,79 @@ INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/us
 INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/posts', 'GET');
 INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/posts/{postId}', 'GET');
 INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/posts/{postId}/comments', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/posts/{postId}/related', 'GET');
 INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/tags', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/settings', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/search', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/feeds/posts.{format}', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/feeds/tags/{tag}.{format}', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/feeds/users/{username}.{format}', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/search/suggest', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/events', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/users/{username}/followers', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', '*', '/api/{version}/tags/{tag}/followers', 'GET');
+
+--- Authorization Rules for logged in users ("authenticated" matches any logged in user)
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/posts/{postId}', 'POST');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/posts/{postId}/vote', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/posts/{postId}/vote', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/posts/{postId}/bookmark', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/posts/{postId}/bookmark', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/users/{username}/follow', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/users/{username}/follow', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/tags/{tag}/follow', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/tags/{tag}/follow', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/users/{username}/block', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/users/{username}/block', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/comments/{commentId}', 'POST');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/comments/{commentId}/vote', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'authenticated', '/api/{version}/comments/{commentId}/vote', 'DELETE');
+
+--- Authorization Rules for the owners of resources ("owner" matches the user whose username is in the path, or the author of the post/comment in the path)
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/posts/{postId}', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/posts/{postId}/conversion', 'POST');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/posts/{postId}', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/comments/{commentId}', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/comments/{commentId}', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/users/{username}/posts', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/users/{username}/drafts', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/users/{username}/liked-posts', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/users/{username}/bookmarks', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/users/{username}/feed', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/users/{username}/following', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/users/{username}/blocked', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/users/{username}/comments', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'owner', '/api/{version}/users/{username}/liked-comments', 'GET');
+
+--- Authorization Rules for moderators
+--- Assign the moderator role to a user with: INSERT INTO casbin_rule (Ptype, V0, V1) VALUES ('g', '<username>', 'role:moderator');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:moderator', '/api/{version}/tags/{tag}', 'POST');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:moderator', '/api/{version}/tags/{tag}', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:moderator', '/api/{version}/tags/{tag}/merge', 'POST');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:moderator', '/api/{version}/tags/{tag}/aliases/{alias}', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:moderator', '/api/{version}/tags/{tag}/aliases/{alias}', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:moderator', '/api/{version}/bans', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:moderator', '/api/{version}/users/{username}/ban', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:moderator', '/api/{version}/users/{username}/ban', 'DELETE');
+
+--- Authorization Rules for admins
+--- Assign the admin role to a user with: INSERT INTO casbin_rule (Ptype, V0, V1) VALUES ('g', '<username>', 'role:admin');
+--- Admins are moderators too
+INSERT INTO casbin_rule (Ptype, V0, V1) VALUES ('g', 'role:admin', 'role:moderator');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/settings', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/policies', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/policies', 'POST');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/policies', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/policies/explanation', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/audit-events', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/log-level', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/log-level', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/webhooks', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/webhooks/{webhookId}', 'POST');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/webhooks/{webhookId}', 'PUT');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/webhooks/{webhookId}', 'DELETE');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/webhooks/{webhookId}/deliveries', 'GET');
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES ('p', 'role:admin', '/api/{version}/webhooks/{webhookId}/deliveries/{deliveryId}/redelivery', 'POST');
 
 -- Seed data (Users)
 INSERT INTO user_account(username, password) VALUES ('Jerry_the_mouse', '$argon2id$v=19$m=65536,t=1,p=12$YE64ezFCyW7QxyX45BPaNQ$/enrxUso87fmQ/Ynd/ynzij+RCJKEaNTXuyj42scaU8');
@@ -141,6 +424,15 @@ INSERT INTO user_account(username, password) VALUES ('Spike_the_dog', '$argon2id
 INSERT INTO user_account(username, password) VALUES ('Nibbles_the_baby', '$argon2id$v=19$m=65536,t=1,p=12$YE64ezFCyW7QxyX45BPaNQ$/enrxUso87fmQ/Ynd/ynzij+RCJKEaNTXuyj42scaU8');
 INSERT INTO user_account(username, password) VALUES ('Quacker_the_duck', '$argon2id$v=19$m=65536,t=1,p=12$YE64ezFCyW7QxyX45BPaNQ$/enrxUso87fmQ/Ynd/ynzij+RCJKEaNTXuyj42scaU8');
 
+-- Seed data (Roles)
+INSERT INTO casbin_rule (Ptype, V0, V1) VALUES ('g', 'Jerry_the_mouse', 'role:admin');
+
+-- Seed data (Tags)
+INSERT INTO tag (name, description, colour) VALUES
+    ('Looking for Advice', 'Ask the community for advice', '#1976d2'),
+    ('Question', 'Ask a question', '#388e3c'),
+    ('Discussion', 'Start a discussion', '#f57c00');
+
 -- Seed data (Post 1 & its comments)
 INSERT INTO post (id, title, body, author, status, created_at)
 VALUES (
@@ -294,6 +586,14 @@ VALUES (
     '2024-12-09 17:01:30.810259+00'
 );
 
+-- Initialise the vote counters of the seed data
+UPDATE post SET 
+    likes = (SELECT COUNT(*) FROM post_vote WHERE post_vote.post_id = post.id AND vote = 'Like'),
+    dislikes = (SELECT COUNT(*) FROM post_vote WHERE post_vote.post_id = post.id AND vote = 'Dislike');
+UPDATE comment SET 
+    likes = (SELECT COUNT(*) FROM comment_vote WHERE comment_vote.comment_id = comment.id AND vote = 'Like'),
+    dislikes = (SELECT COUNT(*) FROM comment_vote WHERE comment_vote.comment_id = comment.id AND vote = 'Dislike');
+
 --- Docker commands
 --- docker run --name dev -p 5433:5432 -e POSTGRES_PASSWORD=abcd1234 -e POSTGRES_DB=backend -v C:\Users\lekwc\Documents\Coding\Projects\CVWO Assignment\nus-confess-it-fake\backend\db_init.sql:/docker-entrypoint-initdb.d/init.sql -d postgres 
 --- docker exec -it dev psql -U backend
@@ -0,0 +1,138 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// An Event describes a change that is pushed to clients over Server-Sent Events
+// PostId is set for events that belong to a post (e.g. a new comment) so that clients watching the post receive them
+// Recipient is set for events that are addressed to a single user (i.e. notifications)
+// Note: Data must be kept small because events are relayed between instances with NOTIFY, which has an 8000 byte limit
+type Event struct {
+	Type      string          `json:"type"`
+	PostId    string          `json:"postId,omitempty"`
+	Recipient string          `json:"recipient,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Creates an event with its data encoded as JSON
+func NewEvent(eventType string, postId string, recipient string, data any) (Event, error) {
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+
+	return Event{
+		Type:      eventType,
+		PostId:    postId,
+		Recipient: recipient,
+		Data:      encodedData,
+	}, nil
+}
+
+// A Relay forwards published events to every instance of the backend (including this one)
+// Each instance is then responsible for dispatching the events to its own subscribers
+type Relay interface {
+	Publish(event Event) error
+}
+
+// A Subscription receives all events on the watched post and all events addressed to its user
+// PostId and Username are both optional
+type Subscription struct {
+	PostId   string
+	Username string
+	Events   chan Event
+}
+
+func (s *Subscription) matches(event Event) bool {
+	if event.Recipient != "" {
+		// Events addressed to a user are only sent to that user
+		return s.Username != "" && event.Recipient == s.Username
+	}
+
+	return s.PostId != "" && event.PostId == s.PostId
+}
+
+// The number of events that can be queued for a subscriber before new events are dropped
+// This way, a slow client cannot block the handlers that publish events
+const subscriptionBufferSize = 32
+
+// An in-process publish/subscribe hub
+type Hub struct {
+	mu            sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+	relay         Relay
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscriptions: map[*Subscription]struct{}{},
+	}
+}
+
+// Sets a relay so that published events reach the subscribers of all instances
+// The relay must call Dispatch on this hub for every event it receives
+func (h *Hub) SetRelay(relay Relay) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.relay = relay
+}
+
+func (h *Hub) Subscribe(postId string, username string) *Subscription {
+	subscription := &Subscription{
+		PostId:   postId,
+		Username: username,
+		Events:   make(chan Event, subscriptionBufferSize),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.subscriptions[subscription] = struct{}{}
+
+	return subscription
+}
+
+func (h *Hub) Unsubscribe(subscription *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscriptions[subscription]; ok {
+		delete(h.subscriptions, subscription)
+		close(subscription.Events)
+	}
+}
+
+// Publishes the event through the relay if there is one. Otherwise, dispatches it to the local subscribers directly
+func (h *Hub) Publish(event Event) error {
+	h.mu.RLock()
+	relay := h.relay
+	h.mu.RUnlock()
+
+	if relay != nil {
+		return relay.Publish(event)
+	}
+
+	h.Dispatch(event)
+	return nil
+}
+
+// Sends the event to every local subscriber it matches
+// Events are dropped for subscribers whose buffer is full instead of blocking
+func (h *Hub) Dispatch(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for subscription := range h.subscriptions {
+		if !subscription.matches(event) {
+			continue
+		}
+
+		select {
+		case subscription.Events <- event:
+		default:
+		}
+	}
+}
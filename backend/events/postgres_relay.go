@@ -0,0 +1,86 @@
+package events
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const notifyChannel = "events"
+
+// Relays events between backend instances with Postgres LISTEN/NOTIFY
+// Every instance listens on the same channel, so an event published by one instance is dispatched by all of them
+type PostgresRelay struct {
+	db       *sql.DB
+	listener *pq.Listener
+	hub      *Hub
+	logger   *slog.Logger
+}
+
+func NewPostgresRelay(connStr string, hub *Hub, logger *slog.Logger) (*PostgresRelay, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	reportProblem := func(eventType pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warn("EVENT-LISTENER-CONNECTION-PROBLEM", "errorMessage", err.Error())
+		}
+	}
+	listener := pq.NewListener(connStr, 10*time.Second, time.Minute, reportProblem)
+	if err := listener.Listen(notifyChannel); err != nil {
+		return nil, err
+	}
+
+	relay := &PostgresRelay{
+		db:       db,
+		listener: listener,
+		hub:      hub,
+		logger:   logger,
+	}
+	go relay.listen()
+
+	return relay, nil
+}
+
+func (relay *PostgresRelay) Publish(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = relay.db.Exec(`SELECT pg_notify($1, $2)`, notifyChannel, string(payload))
+	return err
+}
+
+func (relay *PostgresRelay) listen() {
+	for notification := range relay.listener.Notify {
+		if notification == nil {
+			// A nil notification is sent after the listener reconnects. Notifications sent in the meantime are lost
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+			relay.logger.Warn("EVENT-DECODING-FAILED", "errorMessage", err.Error())
+			continue
+		}
+
+		relay.hub.Dispatch(event)
+	}
+}
+
+func (relay *PostgresRelay) Close() error {
+	if err := relay.listener.Close(); err != nil {
+		return err
+	}
+	return relay.db.Close()
+}
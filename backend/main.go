@@ -1,11 +1,18 @@
 package main
 
 import (
+	"backend/events"
+	"backend/policysync"
 	"backend/postgres"
 	"backend/routes"
+	"backend/webhooks"
+	"context"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	pgadapter "github.com/casbin/casbin-pg-adapter"
@@ -16,8 +23,16 @@ import (
 
 func main() {
 	// Logger is instantiated first so it can be used to log the application start up
-	logOutputMedium := os.Stdout
-	rootLogger := routes.NewRootLogger(logOutputMedium)
+	// Configuration errors are reported with the standard logger, since the root logger does not exist yet
+	loggerOptions, logOutputMedium, err := loadLoggerConfig()
+	if err != nil {
+		log.Fatalf("Logger could not be configured: %s", err)
+	}
+	// Only a log file is closed, as os.Stdout is not ours to close
+	if logFile, ok := logOutputMedium.(*routes.RotatingFile); ok {
+		defer logFile.Close()
+	}
+	rootLogger := routes.NewRootLogger(logOutputMedium, loggerOptions)
 
 	routes.AuthSecretKey = []byte(os.Getenv("AUTH_SECRET_KEY"))
 	backendPort := os.Getenv("BACKEND_PORT")
@@ -46,6 +61,17 @@ func main() {
 	}
 	rootLogger.Info("DB-CONNECTION-ESTABLISHED", "user", opts.User, "host", opts.Addr, "database", opts.Database)
 
+	// "backend reconcile-vote-counters" repairs the vote counters of posts and comments instead of starting the server
+	if len(os.Args) > 1 && os.Args[1] == "reconcile-vote-counters" {
+		postsRepaired, commentsRepaired, err := postgresStore.ReconcileVoteCounters()
+		if err != nil {
+			rootLogger.Fatal("VOTE-COUNTER-RECONCILIATION-FAILED", "errorMessage", fmt.Sprintf("Could not reconcile vote counters: %s", err))
+		}
+
+		rootLogger.Info("VOTE-COUNTERS-RECONCILED", "postsRepaired", postsRepaired, "commentsRepaired", commentsRepaired)
+		return
+	}
+
 
 	// A Translator maps tags to text templates (you must register these tags & templates yourself)
 	// In the case of cardinals & ordinals, numerical parameters are also taken into account
@@ -73,7 +99,8 @@ func main() {
 	}
 
 	m, _ := model.NewModelFromString(routes.AuthModel)
-	authEnforcer, err := casbin.NewEnforcer(m, a)
+	// The synced enforcer is safe to use concurrently, which is needed as the policy watcher changes it in the background
+	authEnforcer, err := casbin.NewSyncedEnforcer(m, a)
 	if err != nil {
 		rootLogger.Fatal("AUTHORIZATION-ENFORCER-INSTANTIATION-FAILED", "errorMessage", fmt.Sprintf("Could not instantiate Authorization Enforcer: %s", err))
 	} else {
@@ -86,9 +113,127 @@ func main() {
 		rootLogger.Info("AUTHORIZATION-POLICY-LOADED")
 	}
 
-	router := routes.NewRouter(postgresStore, universalTranslator, validate, rootLogger, authEnforcer)
+	// Policy changes are saved to the DB one rule at a time (AutoSave), and relayed to the other instances by the watcher
+	policyWatcher, err := policysync.NewPostgresWatcher(dbConnString, rootLogger.Logger)
+	if err != nil {
+		rootLogger.Fatal("AUTHORIZATION-WATCHER-INSTANTIATION-FAILED", "errorMessage", fmt.Sprintf("Could not instantiate Authorization Watcher: %s", err))
+	} else {
+		rootLogger.Info("AUTHORIZATION-WATCHER-INSTANTIATED", "user", opts.User, "host", opts.Addr, "database", opts.Database)
+	}
+	defer policyWatcher.Close()
+
+	authEnforcer.EnableAutoSave(true)
+	if err := authEnforcer.SetWatcher(policyWatcher); err != nil {
+		rootLogger.Fatal("AUTHORIZATION-WATCHER-REGISTRATION-FAILED", "errorMessage", fmt.Sprintf("Could not register Authorization Watcher: %s", err))
+	}
+	policyWatcher.SetUpdateCallback(policysync.NewUpdateCallback(authEnforcer, rootLogger.Logger))
+
+	// Events are dispatched in-process by default
+	// Deployments with multiple instances should set EVENTS_FANOUT=postgres so events are relayed to every instance
+	eventHub := events.NewHub()
+	if os.Getenv("EVENTS_FANOUT") == "postgres" {
+		relay, err := events.NewPostgresRelay(dbConnString, eventHub, rootLogger.Logger)
+		if err != nil {
+			rootLogger.Fatal("EVENT-RELAY-INSTANTIATION-FAILED", "errorMessage", fmt.Sprintf("Could not instantiate Event Relay: %s", err))
+		} else {
+			rootLogger.Info("EVENT-RELAY-INSTANTIATED", "user", opts.User, "host", opts.Addr, "database", opts.Database)
+		}
+		defer relay.Close()
+
+		eventHub.SetRelay(relay)
+	}
+
+	// Refresh the hot scores of posts in the background
+	hotScoreRefreshInterval := 5 * time.Minute
+	go refreshHotScoresPeriodically(postgresStore, rootLogger, hotScoreRefreshInterval)
+	rootLogger.Info("HOT-SCORE-REFRESHER-STARTED", "interval", hotScoreRefreshInterval.String())
+
+	// Send the deliveries in the webhook outbox in the background
+	webhookDispatcher := webhooks.NewDispatcher(postgresStore, rootLogger.Logger)
+	go webhookDispatcher.Run(context.Background())
+	rootLogger.Info("WEBHOOK-DISPATCHER-STARTED")
+
+	router := routes.NewRouter(postgresStore, universalTranslator, validate, rootLogger, authEnforcer, eventHub)
 
 	rootLogger.Info("STARTING-UP")
 	rootLogger.Info("SERVER-STARTED", "address", listenAddress)
 	http.ListenAndServe(listenAddress, router)
 }
+
+// Recalculates the hot scores of posts immediately and then at every interval
+func refreshHotScoresPeriodically(postgresStore *postgres.PostgresStore, logger *routes.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		updatedCount, err := postgresStore.RefreshHotScores()
+		if err != nil {
+			logger.Error("HOT-SCORE-REFRESH-FAILED", "errorMessage", err.Error())
+		} else {
+			logger.Info("HOT-SCORES-REFRESHED", "updatedCount", updatedCount)
+		}
+
+		<-ticker.C
+	}
+}
+
+// Reads the logger configuration from the environment:
+// LOG_LEVEL is the minimum level ("debug", "info", "warn", "error" or "fatal"), "info" by default
+// LOG_FORMAT is "json" (the default) or "text"
+// LOG_OUTPUT is "stdout" (the default) or the path of a log file, which is rotated once it reaches
+// LOG_FILE_MAX_SIZE_MB megabytes (100 by default). LOG_FILE_MAX_BACKUPS rotated files are kept (5 by default)
+// Secrets in the logs are fingerprinted instead of only masked if LOG_FINGERPRINT_KEY is set
+func loadLoggerConfig() (routes.LoggerOptions, io.Writer, error) {
+	options := routes.LoggerOptions{
+		Format:         os.Getenv("LOG_FORMAT"),
+		FingerprintKey: []byte(os.Getenv("LOG_FINGERPRINT_KEY")),
+	}
+
+	if levelName := os.Getenv("LOG_LEVEL"); levelName != "" {
+		level, err := routes.ParseLogLevel(levelName)
+		if err != nil {
+			return options, nil, err
+		}
+		options.Level = level
+	}
+
+	if options.Format != "" && options.Format != "json" && options.Format != "text" {
+		return options, nil, fmt.Errorf("unknown log format %q", options.Format)
+	}
+
+	output := os.Getenv("LOG_OUTPUT")
+	if output == "" || output == "stdout" {
+		return options, os.Stdout, nil
+	}
+
+	maxSizeMB, err := getPositiveIntEnv("LOG_FILE_MAX_SIZE_MB", 100)
+	if err != nil {
+		return options, nil, err
+	}
+	maxBackups, err := getPositiveIntEnv("LOG_FILE_MAX_BACKUPS", 5)
+	if err != nil {
+		return options, nil, err
+	}
+
+	logFile, err := routes.NewRotatingFile(output, int64(maxSizeMB)*1024*1024, maxBackups)
+	if err != nil {
+		return options, nil, err
+	}
+
+	return options, logFile, nil
+}
+
+// Returns the default value if the environment variable is not set
+func getPositiveIntEnv(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsedValue, err := strconv.Atoi(value)
+	if err != nil || parsedValue < 1 {
+		return 0, fmt.Errorf("%s must be a positive whole number", key)
+	}
+
+	return parsedValue, nil
+}
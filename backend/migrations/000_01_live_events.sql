@@ -0,0 +1,10 @@
+--- Adds the rule of the Server-Sent Events stream of live updates
+--- Run with: psql -U postgres -d backend -f 000_01_live_events.sql
+
+BEGIN;
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', '*', '/api/{version}/events', 'GET')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
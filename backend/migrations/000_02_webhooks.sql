@@ -0,0 +1,69 @@
+--- Adds the webhooks, their delivery outbox and the admin rules to manage them
+--- Assign the admin role to a user with: INSERT INTO casbin_rule (Ptype, V0, V1) VALUES ('g', '<username>', 'admin');
+--- Run with: psql -U postgres -d backend -f 000_02_webhooks.sql
+
+BEGIN;
+
+DO $$
+BEGIN
+    CREATE TYPE WEBHOOK_DELIVERY_STATUS AS ENUM (
+        'Pending',
+        'Delivered',
+        'Failed'
+    );
+EXCEPTION
+    WHEN duplicate_object THEN NULL;
+END $$;
+
+CREATE TABLE IF NOT EXISTS webhook (
+    id UUID PRIMARY KEY,
+    target_url VARCHAR(2000) NOT NULL,
+    secret VARCHAR(200) NOT NULL,
+    event_types VARCHAR(30)[] NOT NULL,
+    tags VARCHAR(30)[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_by VARCHAR(20) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (created_by) REFERENCES user_account(username)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id UUID NOT NULL,
+    event_type VARCHAR(30) NOT NULL,
+    payload JSONB NOT NULL,
+    status WEBHOOK_DELIVERY_STATUS NOT NULL DEFAULT 'Pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (webhook_id) REFERENCES webhook(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_pending_idx ON webhook_delivery (next_attempt_at) WHERE status = 'Pending';
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempt (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    delivery_id UUID NOT NULL,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    response_status INT,
+    error_message TEXT,
+    duration_ms BIGINT NOT NULL,
+
+    FOREIGN KEY (delivery_id) REFERENCES webhook_delivery(id) ON DELETE CASCADE
+);
+
+-- 007_role_namespace.sql renames the admin role to "role:admin"
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'admin', '/api/{version}/webhooks', 'GET'),
+    ('p', 'admin', '/api/{version}/webhooks/{webhookId}', 'POST'),
+    ('p', 'admin', '/api/{version}/webhooks/{webhookId}', 'PUT'),
+    ('p', 'admin', '/api/{version}/webhooks/{webhookId}', 'DELETE'),
+    ('p', 'admin', '/api/{version}/webhooks/{webhookId}/deliveries', 'GET'),
+    ('p', 'admin', '/api/{version}/webhooks/{webhookId}/deliveries/{deliveryId}/redelivery', 'POST')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,16 @@
+--- Adds the private bookmarks of posts
+--- Run with: psql -U postgres -d backend -f 000_03_post_bookmarks.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS post_bookmark (
+    viewer VARCHAR(20),
+    post_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (viewer, post_id),
+    FOREIGN KEY (viewer) REFERENCES user_account(username),
+    FOREIGN KEY (post_id) REFERENCES post(id)
+);
+
+COMMIT;
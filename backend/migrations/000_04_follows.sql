@@ -0,0 +1,36 @@
+--- Adds the follows of users and tags and the public rules to list their followers
+--- Run with: psql -U postgres -d backend -f 000_04_follows.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_follow (
+    follower VARCHAR(20),
+    followee VARCHAR(20),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (follower, followee),
+    FOREIGN KEY (follower) REFERENCES user_account(username),
+    FOREIGN KEY (followee) REFERENCES user_account(username),
+    CHECK (follower <> followee)
+);
+
+CREATE INDEX IF NOT EXISTS user_follow_followee_idx ON user_follow (followee);
+
+CREATE TABLE IF NOT EXISTS tag_follow (
+    follower VARCHAR(20),
+    tag VARCHAR(30),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (follower, tag),
+    FOREIGN KEY (follower) REFERENCES user_account(username)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tag_follow_lower_idx ON tag_follow (follower, lower(tag));
+CREATE INDEX IF NOT EXISTS tag_follow_tag_lower_idx ON tag_follow (lower(tag));
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', '*', '/api/{version}/users/{username}/followers', 'GET'),
+    ('p', '*', '/api/{version}/tags/{tag}/followers', 'GET')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,15 @@
+--- Adds the hot scores of posts and the times of post votes
+--- The backend calculates the hot scores when it starts, so they are left at 0 here
+--- When the existing votes were cast is unknown, so they are treated as cast when their post was created
+--- Run with: psql -U postgres -d backend -f 000_05_hot_sort.sql
+
+BEGIN;
+
+ALTER TABLE post ADD COLUMN IF NOT EXISTS hot_score DOUBLE PRECISION NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS post_hot_score_idx ON post (hot_score DESC);
+
+ALTER TABLE post_vote ADD COLUMN IF NOT EXISTS voted_at TIMESTAMPTZ;
+UPDATE post_vote SET voted_at = post.created_at FROM post WHERE post_vote.post_id = post.id AND post_vote.voted_at IS NULL;
+ALTER TABLE post_vote ALTER COLUMN voted_at SET DEFAULT now(), ALTER COLUMN voted_at SET NOT NULL;
+
+COMMIT;
@@ -0,0 +1,18 @@
+--- Adds the vote counters of posts and comments, counted from the existing votes
+--- Run with: psql -U postgres -d backend -f 000_06_vote_counters.sql
+
+BEGIN;
+
+ALTER TABLE post ADD COLUMN IF NOT EXISTS likes INT NOT NULL DEFAULT 0;
+ALTER TABLE post ADD COLUMN IF NOT EXISTS dislikes INT NOT NULL DEFAULT 0;
+ALTER TABLE comment ADD COLUMN IF NOT EXISTS likes INT NOT NULL DEFAULT 0;
+ALTER TABLE comment ADD COLUMN IF NOT EXISTS dislikes INT NOT NULL DEFAULT 0;
+
+UPDATE post SET
+    likes = (SELECT COUNT(*) FROM post_vote WHERE post_vote.post_id = post.id AND vote = 'Like'),
+    dislikes = (SELECT COUNT(*) FROM post_vote WHERE post_vote.post_id = post.id AND vote = 'Dislike');
+UPDATE comment SET
+    likes = (SELECT COUNT(*) FROM comment_vote WHERE comment_vote.comment_id = comment.id AND vote = 'Like'),
+    dislikes = (SELECT COUNT(*) FROM comment_vote WHERE comment_vote.comment_id = comment.id AND vote = 'Dislike');
+
+COMMIT;
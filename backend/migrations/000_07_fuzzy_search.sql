@@ -0,0 +1,15 @@
+--- Adds the trigram indexes of the typo-tolerant search and the rule of the title autocomplete
+--- Run with: psql -U postgres -d backend -f 000_07_fuzzy_search.sql
+
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS post_title_trgm_idx ON post USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS tag_lower_trgm_idx ON post_tag USING GIN (lower(tag) gin_trgm_ops);
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', '*', '/api/{version}/search/suggest', 'GET')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
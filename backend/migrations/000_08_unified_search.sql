@@ -0,0 +1,10 @@
+--- Adds the rule of the unified search across posts, comments and users
+--- Run with: psql -U postgres -d backend -f 000_08_unified_search.sql
+
+BEGIN;
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', '*', '/api/{version}/search', 'GET')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,66 @@
+--- Adds the tag catalogue, with the seed tags and every tag already used by a post, and the app settings
+--- Free-form tags stay allowed, as they were before the tag catalogue
+--- Run with: psql -U postgres -d backend -f 000_09_tag_catalogue.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS tag (
+    name VARCHAR(30) PRIMARY KEY,
+    description VARCHAR(500) NOT NULL DEFAULT '',
+    colour VARCHAR(7) NOT NULL DEFAULT '#9e9e9e',
+    retired BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tag_name_lower_idx ON tag (lower(name));
+
+INSERT INTO tag (name, description, colour) VALUES
+    ('Looking for Advice', 'Ask the community for advice', '#1976d2'),
+    ('Question', 'Ask a question', '#388e3c'),
+    ('Discussion', 'Start a discussion', '#f57c00')
+ON CONFLICT DO NOTHING;
+
+-- Tags that only differ in case become one tag, named after the first spelling alphabetically
+INSERT INTO tag (name)
+SELECT min(tag) FROM post_tag GROUP BY lower(tag)
+ON CONFLICT DO NOTHING;
+
+DELETE FROM post_tag AS duplicate
+USING post_tag AS kept
+WHERE duplicate.post_id = kept.post_id AND lower(duplicate.tag) = lower(kept.tag) AND duplicate.tag > kept.tag;
+
+UPDATE post_tag SET tag = tag.name
+FROM tag
+WHERE lower(post_tag.tag) = lower(tag.name) AND post_tag.tag <> tag.name;
+
+ALTER TABLE post_tag
+    DROP CONSTRAINT IF EXISTS post_tag_tag_fkey,
+    ADD CONSTRAINT post_tag_tag_fkey FOREIGN KEY (tag) REFERENCES tag(name) ON UPDATE CASCADE;
+
+CREATE INDEX IF NOT EXISTS post_tag_tag_idx ON post_tag (tag);
+
+CREATE TABLE IF NOT EXISTS app_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    allow_freeform_tags BOOLEAN NOT NULL DEFAULT true,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO app_settings DEFAULT VALUES ON CONFLICT DO NOTHING;
+
+--- Rules for non-logged in users
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', '*', '/api/{version}/settings', 'GET')
+ON CONFLICT DO NOTHING;
+
+--- Rules for moderators and admins. 007_role_namespace.sql renames the roles to "role:moderator" and "role:admin"
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'moderator', '/api/{version}/tags/{tag}', 'POST'),
+    ('p', 'moderator', '/api/{version}/tags/{tag}', 'PUT'),
+    ('p', 'moderator', '/api/{version}/tags/{tag}/merge', 'POST'),
+    ('p', 'admin', '/api/{version}/settings', 'PUT')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO casbin_rule (Ptype, V0, V1) VALUES ('g', 'admin', 'moderator') ON CONFLICT DO NOTHING;
+
+COMMIT;
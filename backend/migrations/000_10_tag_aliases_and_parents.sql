@@ -0,0 +1,33 @@
+--- Adds the parents and aliases of tags and the moderator rules to manage the aliases
+--- Run with: psql -U postgres -d backend -f 000_10_tag_aliases_and_parents.sql
+
+BEGIN;
+
+ALTER TABLE tag ADD COLUMN IF NOT EXISTS parent VARCHAR(30);
+
+ALTER TABLE tag
+    DROP CONSTRAINT IF EXISTS tag_parent_fkey,
+    ADD CONSTRAINT tag_parent_fkey FOREIGN KEY (parent) REFERENCES tag(name) ON UPDATE CASCADE,
+    DROP CONSTRAINT IF EXISTS tag_check,
+    ADD CONSTRAINT tag_check CHECK (parent <> name);
+
+CREATE INDEX IF NOT EXISTS tag_parent_idx ON tag (parent);
+
+CREATE TABLE IF NOT EXISTS tag_alias (
+    alias VARCHAR(30) PRIMARY KEY,
+    tag VARCHAR(30) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (tag) REFERENCES tag(name) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tag_alias_lower_idx ON tag_alias (lower(alias));
+CREATE INDEX IF NOT EXISTS tag_alias_tag_idx ON tag_alias (tag);
+
+-- 007_role_namespace.sql renames the moderator role to "role:moderator"
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'moderator', '/api/{version}/tags/{tag}/aliases/{alias}', 'PUT'),
+    ('p', 'moderator', '/api/{version}/tags/{tag}/aliases/{alias}', 'DELETE')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,10 @@
+--- Adds the rule of the related posts endpoint
+--- Run with: psql -U postgres -d backend -f 000_11_related_posts.sql
+
+BEGIN;
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', '*', '/api/{version}/posts/{postId}/related', 'GET')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,12 @@
+--- Adds the rules of the RSS and Atom feeds
+--- Run with: psql -U postgres -d backend -f 000_12_feeds.sql
+
+BEGIN;
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', '*', '/api/{version}/feeds/posts.{format}', 'GET'),
+    ('p', '*', '/api/{version}/feeds/tags/{tag}.{format}', 'GET'),
+    ('p', '*', '/api/{version}/feeds/users/{username}.{format}', 'GET')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,10 @@
+--- Adds the versions of posts and comments used by optimistic concurrency control
+--- Existing posts and comments start at the first version
+--- Run with: psql -U postgres -d backend -f 000_13_optimistic_concurrency.sql
+
+BEGIN;
+
+ALTER TABLE post ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+ALTER TABLE comment ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+COMMIT;
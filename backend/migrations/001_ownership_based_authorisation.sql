@@ -0,0 +1,60 @@
+--- Migrates a database created before ownership-based authorisation
+--- Every user used to get their own copy of the rules for logged in users, and the authors of posts and comments
+--- got rules for each of their posts and comments. These are replaced by the generic "authenticated" and "owner" rules
+--- Run with: psql -U postgres -d backend -f 001_ownership_based_authorisation.sql
+
+BEGIN;
+
+--- Generic rules for logged in users
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'authenticated', '/api/{version}/posts/{postId}', 'POST'),
+    ('p', 'authenticated', '/api/{version}/posts/{postId}/vote', 'PUT'),
+    ('p', 'authenticated', '/api/{version}/posts/{postId}/vote', 'DELETE'),
+    ('p', 'authenticated', '/api/{version}/posts/{postId}/bookmark', 'PUT'),
+    ('p', 'authenticated', '/api/{version}/posts/{postId}/bookmark', 'DELETE'),
+    ('p', 'authenticated', '/api/{version}/users/{username}/follow', 'PUT'),
+    ('p', 'authenticated', '/api/{version}/users/{username}/follow', 'DELETE'),
+    ('p', 'authenticated', '/api/{version}/tags/{tag}/follow', 'PUT'),
+    ('p', 'authenticated', '/api/{version}/tags/{tag}/follow', 'DELETE'),
+    ('p', 'authenticated', '/api/{version}/comments/{commentId}', 'POST'),
+    ('p', 'authenticated', '/api/{version}/comments/{commentId}/vote', 'PUT'),
+    ('p', 'authenticated', '/api/{version}/comments/{commentId}/vote', 'DELETE')
+ON CONFLICT DO NOTHING;
+
+--- Generic rules for the owners of resources
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'owner', '/api/{version}/posts/{postId}', 'PUT'),
+    ('p', 'owner', '/api/{version}/posts/{postId}/conversion', 'POST'),
+    ('p', 'owner', '/api/{version}/posts/{postId}', 'DELETE'),
+    ('p', 'owner', '/api/{version}/comments/{commentId}', 'PUT'),
+    ('p', 'owner', '/api/{version}/comments/{commentId}', 'DELETE'),
+    ('p', 'owner', '/api/{version}/users/{username}/posts', 'GET'),
+    ('p', 'owner', '/api/{version}/users/{username}/drafts', 'GET'),
+    ('p', 'owner', '/api/{version}/users/{username}/liked-posts', 'GET'),
+    ('p', 'owner', '/api/{version}/users/{username}/bookmarks', 'GET'),
+    ('p', 'owner', '/api/{version}/users/{username}/feed', 'GET'),
+    ('p', 'owner', '/api/{version}/users/{username}/following', 'GET'),
+    ('p', 'owner', '/api/{version}/users/{username}/comments', 'GET'),
+    ('p', 'owner', '/api/{version}/users/{username}/liked-comments', 'GET')
+ON CONFLICT DO NOTHING;
+
+--- Per-user copies of the rules for logged in users
+DELETE FROM casbin_rule AS rule
+USING (SELECT p.V1, p.V2 FROM casbin_rule AS p WHERE p.Ptype = 'p' AND p.V0 = 'authenticated') AS generic
+WHERE rule.Ptype = 'p' AND rule.V0 IN (SELECT username FROM user_account)
+  AND rule.V1 = generic.V1 AND rule.V2 = generic.V2;
+
+--- Per-user rules for the user's own listings (e.g. /api/{version}/users/Tom_the_cat/drafts)
+DELETE FROM casbin_rule AS rule
+USING (SELECT p.V1, p.V2 FROM casbin_rule AS p WHERE p.Ptype = 'p' AND p.V0 = 'owner' AND p.V1 LIKE '/api/{version}/users/%') AS generic
+WHERE rule.Ptype = 'p' AND rule.V0 IN (SELECT username FROM user_account)
+  AND rule.V1 = replace(generic.V1, '{username}', rule.V0) AND rule.V2 = generic.V2;
+
+--- Per-resource rules for the authors of posts and comments (e.g. /api/{version}/posts/<post id>/conversion)
+DELETE FROM casbin_rule AS rule
+WHERE rule.Ptype = 'p' AND rule.V0 IN (SELECT username FROM user_account)
+  AND (rule.V1 IN (SELECT '/api/{version}/posts/' || post.id FROM post WHERE post.author = rule.V0)
+       OR rule.V1 IN (SELECT '/api/{version}/posts/' || post.id || '/conversion' FROM post WHERE post.author = rule.V0)
+       OR rule.V1 IN (SELECT '/api/{version}/comments/' || comment.id FROM comment WHERE comment.author = rule.V0));
+
+COMMIT;
@@ -0,0 +1,30 @@
+--- Adds the audit trail of policy changes and the admin rules of the policy admin API
+--- Run with: psql -U postgres -d backend -f 002_policy_admin_api.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS policy_audit (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor VARCHAR(20) NOT NULL,
+    operation VARCHAR(10) NOT NULL CHECK (operation IN ('add', 'remove')),
+    ptype VARCHAR(300) NOT NULL CHECK (ptype IN ('p', 'g')),
+    subject VARCHAR(300) NOT NULL,
+    object VARCHAR(300) NOT NULL,
+    action VARCHAR(300) NOT NULL DEFAULT '',
+    reason VARCHAR(500) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (actor) REFERENCES user_account(username)
+);
+
+CREATE INDEX IF NOT EXISTS policy_audit_created_at_idx ON policy_audit (created_at DESC);
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'role:admin', '/api/{version}/policies', 'GET'),
+    ('p', 'role:admin', '/api/{version}/policies', 'POST'),
+    ('p', 'role:admin', '/api/{version}/policies', 'DELETE'),
+    ('p', 'role:admin', '/api/{version}/policies/explanation', 'GET'),
+    ('p', 'role:admin', '/api/{version}/policies/audit', 'GET')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,24 @@
+--- Adds user bans and the moderator rules to manage them
+--- Run with: psql -U postgres -d backend -f 003_user_bans.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_ban (
+    username VARCHAR(20) PRIMARY KEY,
+    type VARCHAR(10) NOT NULL CHECK (type IN ('Ban', 'ShadowBan')),
+    reason VARCHAR(500) NOT NULL,
+    issued_by VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    FOREIGN KEY (username) REFERENCES user_account(username),
+    FOREIGN KEY (issued_by) REFERENCES user_account(username)
+);
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'role:moderator', '/api/{version}/bans', 'GET'),
+    ('p', 'role:moderator', '/api/{version}/users/{username}/ban', 'PUT'),
+    ('p', 'role:moderator', '/api/{version}/users/{username}/ban', 'DELETE')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,23 @@
+--- Adds user blocks and the rules to manage them
+--- Run with: psql -U postgres -d backend -f 004_user_blocks.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS user_block (
+    blocker VARCHAR(20),
+    blocked VARCHAR(20),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+    PRIMARY KEY (blocker, blocked),
+    FOREIGN KEY (blocker) REFERENCES user_account(username),
+    FOREIGN KEY (blocked) REFERENCES user_account(username),
+    CHECK (blocker <> blocked)
+);
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'authenticated', '/api/{version}/users/{username}/block', 'PUT'),
+    ('p', 'authenticated', '/api/{version}/users/{username}/block', 'DELETE'),
+    ('p', 'owner', '/api/{version}/users/{username}/blocked', 'GET')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,40 @@
+--- Adds the audit log of security and moderation events and the admin rule to query it
+--- Run with: psql -U postgres -d backend -f 005_audit_events.sql
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS audit_event (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    actor VARCHAR(20) NOT NULL DEFAULT '',
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(20) NOT NULL,
+    target_id VARCHAR(300) NOT NULL DEFAULT '',
+    ip VARCHAR(100) NOT NULL DEFAULT '',
+    request_id VARCHAR(36) NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+REVOKE UPDATE, DELETE, TRUNCATE ON audit_event FROM backend;
+
+CREATE INDEX IF NOT EXISTS audit_event_created_at_idx ON audit_event (created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_event_actor_idx ON audit_event (actor, created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_event_target_idx ON audit_event (target_type, target_id, created_at DESC);
+
+-- Carry over the policy changes made so far, so that the audit log is complete
+INSERT INTO audit_event (actor, action, target_type, target_id, metadata, created_at)
+SELECT actor,
+       CASE operation WHEN 'add' THEN 'POLICY-ADDED' ELSE 'POLICY-REMOVED' END,
+       'policy',
+       subject,
+       jsonb_build_object('type', CASE ptype WHEN 'p' THEN 'policy' ELSE 'role' END,
+                          'object', object, 'action', action, 'reason', reason),
+       created_at
+FROM policy_audit
+WHERE NOT EXISTS (SELECT 1 FROM audit_event WHERE target_type = 'policy');
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'role:admin', '/api/{version}/audit-events', 'GET')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
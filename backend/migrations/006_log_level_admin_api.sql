@@ -0,0 +1,11 @@
+--- Adds the admin rules to view and change the log level at runtime
+--- Run with: psql -U postgres -d backend -f 006_log_level_admin_api.sql
+
+BEGIN;
+
+INSERT INTO casbin_rule (Ptype, V0, V1, V2) VALUES
+    ('p', 'role:admin', '/api/{version}/log-level', 'GET'),
+    ('p', 'role:admin', '/api/{version}/log-level', 'PUT')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
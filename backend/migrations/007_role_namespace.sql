@@ -0,0 +1,26 @@
+--- Renames the "admin" and "moderator" roles to "role:admin" and "role:moderator", so that users who sign up
+--- with those usernames do not get the roles' policies. Restart the backend instances afterwards to reload the policies
+--- Run with: psql -U postgres -d backend -f 007_role_namespace.sql
+
+BEGIN;
+
+-- Policies of the roles
+INSERT INTO casbin_rule (Ptype, V0, V1, V2, V3, V4, V5)
+SELECT Ptype, 'role:' || V0, V1, V2, V3, V4, V5 FROM casbin_rule
+WHERE Ptype = 'p' AND V0 IN ('admin', 'moderator')
+ON CONFLICT DO NOTHING;
+
+DELETE FROM casbin_rule WHERE Ptype = 'p' AND V0 IN ('admin', 'moderator');
+
+-- Role assignments, including the one that makes admins moderators too
+INSERT INTO casbin_rule (Ptype, V0, V1, V2, V3, V4, V5)
+SELECT Ptype,
+       CASE WHEN V0 IN ('admin', 'moderator') AND V1 IN ('admin', 'moderator') THEN 'role:' || V0 ELSE V0 END,
+       'role:' || V1, V2, V3, V4, V5
+FROM casbin_rule
+WHERE Ptype = 'g' AND V1 IN ('admin', 'moderator')
+ON CONFLICT DO NOTHING;
+
+DELETE FROM casbin_rule WHERE Ptype = 'g' AND V1 IN ('admin', 'moderator');
+
+COMMIT;
@@ -0,0 +1,11 @@
+--- Drops the audit trail of policy changes, which are recorded in the audit log (audit_event) instead
+--- Run with: psql -U postgres -d backend -f 008_drop_policy_audit.sql
+
+BEGIN;
+
+-- 005_audit_events.sql copied the earlier policy changes into the audit log, and every later change was recorded in both
+DELETE FROM casbin_rule WHERE Ptype = 'p' AND V1 = '/api/{version}/policies/audit' AND V2 = 'GET';
+
+DROP TABLE IF EXISTS policy_audit;
+
+COMMIT;
@@ -0,0 +1,51 @@
+package policysync
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// Returns a watcher callback that applies the policy changes of other instances to the enforcer's in-memory policy
+// The changes are not saved again, as the instance that made them has already saved them to the DB
+// The whole policy is reloaded from the DB if the change is a reload or cannot be decoded
+func NewUpdateCallback(e *casbin.SyncedEnforcer, logger *slog.Logger) func(string) {
+	return func(payload string) {
+		var update policyUpdate
+		if payload == "" || json.Unmarshal([]byte(payload), &update) != nil {
+			update.Operation = "reload"
+		}
+
+		err := applyUpdate(e, update)
+		if err != nil {
+			logger.Error("POLICY-UPDATE-FAILED", "operation", update.Operation, "errorMessage", err.Error())
+			return
+		}
+		logger.Info("POLICY-UPDATED", "operation", update.Operation, "sec", update.Sec, "ptype", update.Ptype, "rules", update.Rules)
+	}
+}
+
+func applyUpdate(e *casbin.SyncedEnforcer, update policyUpdate) error {
+	if update.Operation == "reload" {
+		return e.LoadPolicy() // Takes the lock itself
+	}
+
+	// AutoSave is turned off while the change is applied. The lock keeps other changes from being made meanwhile
+	lock := e.GetLock()
+	lock.Lock()
+	defer lock.Unlock()
+	e.Enforcer.EnableAutoSave(false)
+	defer e.Enforcer.EnableAutoSave(true)
+
+	var err error
+	switch update.Operation {
+	case "add":
+		_, err = e.Enforcer.SelfAddPoliciesEx(update.Sec, update.Ptype, update.Rules)
+	case "remove":
+		_, err = e.Enforcer.SelfRemovePolicies(update.Sec, update.Ptype, update.Rules)
+	case "removeFiltered":
+		_, err = e.Enforcer.SelfRemoveFilteredPolicy(update.Sec, update.Ptype, update.FieldIndex, update.FieldValues...)
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package policysync
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const notifyChannel = "casbin_policies"
+
+// Postgres rejects NOTIFY payloads of 8000 bytes or more. Larger changes are sent as reloads instead
+const maxPayloadSize = 7900
+
+// A change to the authorization policies, as sent to the other backend instances
+// Operation is "add", "remove", "removeFiltered" or "reload" (reload the whole policy from the DB)
+type policyUpdate struct {
+	InstanceId  string     `json:"instanceId"`
+	Operation   string     `json:"operation"`
+	Sec         string     `json:"sec,omitempty"`
+	Ptype       string     `json:"ptype,omitempty"`
+	Rules       [][]string `json:"rules,omitempty"`
+	FieldIndex  int        `json:"fieldIndex,omitempty"`
+	FieldValues []string   `json:"fieldValues,omitempty"`
+}
+
+// A casbin watcher that keeps the policies of backend instances in sync with Postgres LISTEN/NOTIFY
+// The enforcer writes every change to the DB itself (AutoSave) and then tells the watcher, which notifies the other
+// instances so that they can apply the same change in memory. Instances ignore their own notifications
+type PostgresWatcher struct {
+	db         *sql.DB
+	listener   *pq.Listener
+	instanceId string
+	logger     *slog.Logger
+
+	mu       sync.Mutex
+	callback func(string)
+}
+
+var _ persist.WatcherEx = &PostgresWatcher{}
+
+func NewPostgresWatcher(connStr string, logger *slog.Logger) (*PostgresWatcher, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	reportProblem := func(eventType pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warn("POLICY-LISTENER-CONNECTION-PROBLEM", "errorMessage", err.Error())
+		}
+	}
+	listener := pq.NewListener(connStr, 10*time.Second, time.Minute, reportProblem)
+	if err := listener.Listen(notifyChannel); err != nil {
+		return nil, err
+	}
+
+	watcher := &PostgresWatcher{
+		db:         db,
+		listener:   listener,
+		instanceId: uuid.NewString(),
+		logger:     logger,
+	}
+	go watcher.listen()
+
+	return watcher, nil
+}
+
+// The callback receives the payload of the other instances' notifications. An empty payload means that
+// notifications may have been missed, so the whole policy must be reloaded
+func (watcher *PostgresWatcher) SetUpdateCallback(callback func(string)) error {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	watcher.callback = callback
+	return nil
+}
+
+func (watcher *PostgresWatcher) Update() error {
+	return watcher.notify(policyUpdate{Operation: "reload"})
+}
+
+func (watcher *PostgresWatcher) UpdateForAddPolicy(sec, ptype string, params ...string) error {
+	return watcher.notify(policyUpdate{Operation: "add", Sec: sec, Ptype: ptype, Rules: [][]string{params}})
+}
+
+func (watcher *PostgresWatcher) UpdateForRemovePolicy(sec, ptype string, params ...string) error {
+	return watcher.notify(policyUpdate{Operation: "remove", Sec: sec, Ptype: ptype, Rules: [][]string{params}})
+}
+
+func (watcher *PostgresWatcher) UpdateForRemoveFilteredPolicy(sec, ptype string, fieldIndex int, fieldValues ...string) error {
+	return watcher.notify(policyUpdate{
+		Operation: "removeFiltered", Sec: sec, Ptype: ptype, FieldIndex: fieldIndex, FieldValues: fieldValues,
+	})
+}
+
+func (watcher *PostgresWatcher) UpdateForSavePolicy(model model.Model) error {
+	return watcher.notify(policyUpdate{Operation: "reload"})
+}
+
+func (watcher *PostgresWatcher) UpdateForAddPolicies(sec string, ptype string, rules ...[]string) error {
+	return watcher.notify(policyUpdate{Operation: "add", Sec: sec, Ptype: ptype, Rules: rules})
+}
+
+func (watcher *PostgresWatcher) UpdateForRemovePolicies(sec string, ptype string, rules ...[]string) error {
+	return watcher.notify(policyUpdate{Operation: "remove", Sec: sec, Ptype: ptype, Rules: rules})
+}
+
+func (watcher *PostgresWatcher) notify(update policyUpdate) error {
+	update.InstanceId = watcher.instanceId
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxPayloadSize {
+		payload, err = json.Marshal(policyUpdate{InstanceId: watcher.instanceId, Operation: "reload"})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = watcher.db.Exec(`SELECT pg_notify($1, $2)`, notifyChannel, string(payload))
+	return err
+}
+
+func (watcher *PostgresWatcher) listen() {
+	for notification := range watcher.listener.Notify {
+		payload := ""
+		if notification != nil {
+			var update policyUpdate
+			if err := json.Unmarshal([]byte(notification.Extra), &update); err != nil {
+				watcher.logger.Warn("POLICY-UPDATE-DECODING-FAILED", "errorMessage", err.Error())
+			} else if update.InstanceId == watcher.instanceId {
+				continue
+			}
+			payload = notification.Extra
+		}
+		// A nil notification is sent after the listener reconnects. Notifications sent in the meantime are lost,
+		// so the callback is called with an empty payload
+
+		watcher.mu.Lock()
+		callback := watcher.callback
+		watcher.mu.Unlock()
+		if callback != nil {
+			callback(payload)
+		}
+	}
+}
+
+func (watcher *PostgresWatcher) Close() {
+	watcher.listener.Close()
+	watcher.db.Close()
+}
@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"backend/httperror"
+	"encoding/json"
+	"fmt"
+)
+
+// A security or moderation event, e.g. a login, a ban or a soft delete. Audit events are never updated or deleted
+// The actor is empty if nobody was signed in (e.g. when creating an account). Metadata is a JSON object
+type AuditEvent struct {
+	Id         string          `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"targetType"`
+	TargetId   string          `json:"targetId"`
+	Ip         string          `json:"ip"`
+	RequestId  string          `json:"requestId"`
+	Metadata   json.RawMessage `json:"metadata"`
+	CreatedAt  string          `json:"createdAt"`
+}
+
+// All fields are optional. CreatedAfter and CreatedBefore are dates in the "yyyy-mm-dd" format,
+// with the same meaning as in ListingFilter
+type AuditEventFilter struct {
+	Actor         string
+	Action        string
+	TargetType    string
+	TargetId      string
+	CreatedAfter  string
+	CreatedBefore string
+	Limit         int
+	Offset        int
+}
+
+func (postgres *PostgresStore) CreateAuditEvent(event AuditEvent) error {
+	metadata := event.Metadata
+	if len(metadata) == 0 {
+		metadata = json.RawMessage("{}")
+	}
+
+	query := `
+		INSERT INTO audit_event (actor, action, target_type, target_id, ip, request_id, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := postgres.db.Exec(query, event.Actor, event.Action, event.TargetType, event.TargetId, event.Ip, event.RequestId, string(metadata))
+
+	return checkPostgresErr(err)
+}
+
+// Gets the audit events that match the filter, newest first. A limit of 0 means no limit
+func (postgres *PostgresStore) GetAuditEvents(filter AuditEventFilter) ([]AuditEvent, error) {
+	conditionCount := 1
+	conditions := []any{}
+
+	query := `SELECT id, actor, action, target_type, target_id, ip, request_id, metadata, created_at
+			  FROM audit_event
+			  WHERE 1 = 1`
+
+	// Append conditions to the query based on the arguments provided
+	if (filter.Actor != "") {
+		query = query + fmt.Sprintf(" AND actor = $%v", conditionCount)
+		conditions = append(conditions, filter.Actor)
+		conditionCount += 1
+	}
+	if (filter.Action != "") {
+		query = query + fmt.Sprintf(" AND action = $%v", conditionCount)
+		conditions = append(conditions, filter.Action)
+		conditionCount += 1
+	}
+	if (filter.TargetType != "") {
+		query = query + fmt.Sprintf(" AND target_type = $%v", conditionCount)
+		conditions = append(conditions, filter.TargetType)
+		conditionCount += 1
+	}
+	if (filter.TargetId != "") {
+		query = query + fmt.Sprintf(" AND target_id = $%v", conditionCount)
+		conditions = append(conditions, filter.TargetId)
+		conditionCount += 1
+	}
+	if (filter.CreatedAfter != "") {
+		query = query + fmt.Sprintf(" AND created_at >= $%v::date", conditionCount)
+		conditions = append(conditions, filter.CreatedAfter)
+		conditionCount += 1
+	}
+	if (filter.CreatedBefore != "") {
+		query = query + fmt.Sprintf(" AND created_at < $%v::date", conditionCount)
+		conditions = append(conditions, filter.CreatedBefore)
+		conditionCount += 1
+	}
+
+	// The id is used as a tie breaker so that pages do not overlap
+	query = query + fmt.Sprintf(" ORDER BY created_at DESC, id LIMIT NULLIF($%v, 0) OFFSET $%v", conditionCount, conditionCount+1)
+	conditions = append(conditions, filter.Limit, filter.Offset)
+
+	rows, err := postgres.db.Query(query, conditions...)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+
+	for rows.Next() {
+		var event AuditEvent
+		var metadata []byte
+		err := rows.Scan(&event.Id, &event.Actor, &event.Action, &event.TargetType, &event.TargetId, &event.Ip,
+			&event.RequestId, &metadata, &event.CreatedAt)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		}
+		event.Metadata = json.RawMessage(metadata)
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"backend/httperror"
+)
+
+// A ban stops a user from making any changes (e.g. posting, commenting or voting), but they can still read
+// A shadow ban lets the user carry on as usual, but hides their posts and comments from everyone else
+// A ban without an expiry is permanent. A user has at most one ban at a time
+type UserBan struct {
+	Username  string  `json:"username"`
+	Type      string  `json:"type"` // "Ban" or "ShadowBan"
+	Reason    string  `json:"reason"`
+	IssuedBy  string  `json:"issuedBy"`
+	ExpiresAt *string `json:"expiresAt"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// Bans the user, replacing any ban they already have
+func (postgres *PostgresStore) UpsertUserBan(ban UserBan) error {
+	query := `
+		INSERT INTO user_ban (username, type, reason, issued_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (username) DO UPDATE
+		SET type = excluded.type, reason = excluded.reason, issued_by = excluded.issued_by,
+			expires_at = excluded.expires_at, created_at = now()`
+	_, err := postgres.db.Exec(query, ban.Username, ban.Type, ban.Reason, ban.IssuedBy, ban.ExpiresAt)
+
+	return checkPostgresErr(err)
+}
+
+// Lifts the user's ban. Returns false if the user was not banned
+func (postgres *PostgresStore) DeleteUserBan(username string) (bool, error) {
+	query := `DELETE FROM user_ban WHERE username = $1`
+	result, err := postgres.db.Exec(query, username)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, httperror.NewInternalServerError(err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// Gets the bans that have not expired, newest first
+func (postgres *PostgresStore) GetUserBans() ([]UserBan, error) {
+	query := fmt.Sprintf(`SELECT username, type, reason, issued_by, expires_at, created_at
+			  FROM user_ban
+			  WHERE %s
+			  ORDER BY created_at DESC`, activeBanCondition)
+	rows, err := postgres.db.Query(query)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	bans := []UserBan{}
+
+	for rows.Next() {
+		var ban UserBan
+		var expiresAt sql.NullString
+		err := rows.Scan(&ban.Username, &ban.Type, &ban.Reason, &ban.IssuedBy, &expiresAt, &ban.CreatedAt)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			ban.ExpiresAt = &expiresAt.String
+		}
+		bans = append(bans, ban)
+	}
+
+	return bans, nil
+}
+
+// Gets the user's ban if it has not expired, or nil if the user is not banned
+func (postgres *PostgresStore) GetActiveUserBan(username string) (*UserBan, error) {
+	var ban UserBan
+	var expiresAt sql.NullString
+
+	query := fmt.Sprintf(`SELECT username, type, reason, issued_by, expires_at, created_at
+			  FROM user_ban
+			  WHERE username = $1 AND %s`, activeBanCondition)
+	err := postgres.db.QueryRow(query, username).Scan(&ban.Username, &ban.Type, &ban.Reason, &ban.IssuedBy, &expiresAt, &ban.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.String
+	}
+	return &ban, nil
+}
+
+const activeBanCondition = "(user_ban.expires_at IS NULL OR user_ban.expires_at > now())"
+
+// A condition that hides the content of shadow-banned authors from everyone but the authors themselves
+// viewer is the SQL expression of the viewer's username (e.g. "$1"). Use "''" to hide the content from everyone
+func notShadowBanned(authorColumn string, viewer string) string {
+	return fmt.Sprintf(`(%s = %s OR NOT EXISTS (SELECT 1 FROM user_ban WHERE user_ban.username = %s AND user_ban.type = 'ShadowBan' AND %s))`,
+		authorColumn, viewer, authorColumn, activeBanCondition)
+}
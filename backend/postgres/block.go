@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"backend/httperror"
+)
+
+// The blocker no longer sees the posts and comments of the blocked user,
+// and the blocked user can no longer reply to the blocker's comments
+type UserBlock struct {
+	Blocker string
+	Blocked string
+}
+
+func (postgres *PostgresStore) UpsertUserBlock(userBlock UserBlock) error {
+	// Create the block or do nothing if it already exists
+	query := `
+		INSERT INTO user_block (blocker, blocked) VALUES ($1, $2)
+		ON CONFLICT(blocker, blocked) DO NOTHING`
+	_, err := postgres.db.Exec(query, userBlock.Blocker, userBlock.Blocked)
+	return checkPostgresErr(err)
+}
+
+func (postgres *PostgresStore) DeleteUserBlock(userBlock UserBlock) error {
+	query := `
+		DELETE FROM user_block WHERE blocker = $1 AND blocked = $2`
+	_, err := postgres.db.Exec(query, userBlock.Blocker, userBlock.Blocked)
+	return checkPostgresErr(err)
+}
+
+// Gets the users that the user has blocked, most recently blocked first
+func (postgres *PostgresStore) GetBlockedUsers(username string) ([]string, error) {
+	query := `SELECT blocked FROM user_block WHERE blocker = $1 ORDER BY created_at DESC`
+	rows, err := postgres.db.Query(query, username)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	blockedUsers := []string{}
+
+	for rows.Next() {
+		var blocked string
+		err := rows.Scan(&blocked)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		}
+		blockedUsers = append(blockedUsers, blocked)
+	}
+
+	return blockedUsers, nil
+}
+
+// Checks whether the blocker has blocked the user
+func (postgres *PostgresStore) IsUserBlocked(userBlock UserBlock) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM user_block WHERE blocker = $1 AND blocked = $2)`
+	var blocked bool
+	err := postgres.db.QueryRow(query, userBlock.Blocker, userBlock.Blocked).Scan(&blocked)
+	return blocked, checkPostgresErr(err)
+}
+
+// A condition that is true if the viewer has blocked the author
+// viewer is the SQL expression of the viewer's username (e.g. "$1")
+func isBlockedBy(authorColumn string, viewer string) string {
+	return "EXISTS (SELECT 1 FROM user_block WHERE user_block.blocker = " + viewer + " AND user_block.blocked = " + authorColumn + ")"
+}
@@ -0,0 +1,24 @@
+package postgres
+
+import "testing"
+
+func TestIsUserBlocked(t *testing.T) {
+	store := newTestStore(t)
+	userBlock := UserBlock{Blocker: "Tom_the_cat", Blocked: "Jerry_the_mouse"}
+
+	err := store.UpsertUserBlock(userBlock)
+	if err != nil {
+		t.Fatalf("could not block the user: %s", err)
+	}
+	t.Cleanup(func() { store.DeleteUserBlock(userBlock) })
+
+	if blocked, err := store.IsUserBlocked(userBlock); err != nil || !blocked {
+		t.Errorf("the block was not found: blocked %t, error %v", blocked, err)
+	}
+
+	// Blocks only go one way
+	reverse := UserBlock{Blocker: userBlock.Blocked, Blocked: userBlock.Blocker}
+	if blocked, err := store.IsUserBlocked(reverse); err != nil || blocked {
+		t.Errorf("the blocked user was found to block the blocker: blocked %t, error %v", blocked, err)
+	}
+}
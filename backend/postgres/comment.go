@@ -4,7 +4,6 @@ import (
 	"backend/httperror"
 	"database/sql"
 	"fmt"
-	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -21,7 +20,14 @@ type Comment struct {
 	Dislikes      int      `json:"dislikes"`
 	CreatedAt     string   `json:"createdAt"`
 	UpdatedAt     string   `json:"updatedAt"`
+	Version       int      `json:"version"`
 	UserVote      string   `json:"userVote"`
+	Highlight     *CommentHighlight `json:"highlight,omitempty"`
+}
+
+// HTML escaped snippets of a comment that match a search query, with the matches wrapped in <mark> tags
+type CommentHighlight struct {
+	Body string `json:"body"`
 }
 
 type CommentVote struct {
@@ -30,94 +36,158 @@ type CommentVote struct {
 	Vote      string
 }
 
-func (postgres *PostgresStore) CreateComment(comment Comment) error {
-	// Parent id is optional, so let it be null if parent id is not provided
+type CommentVoteCount struct {
+	CommentId string `json:"commentId"`
+	PostId    string `json:"postId"`
+	Likes     int    `json:"likes"`
+	Dislikes  int    `json:"dislikes"`
+}
+
+// The webhook event (optional) is enqueued in the same transaction
+func (postgres *PostgresStore) CreateComment(comment Comment, event *WebhookEvent) error {
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+	defer tx.Rollback()
 
-	var err error
+	// Parent id is optional, so let it be null if parent id is not provided
 	if comment.ParentComment == nil {
 		query := `
 			INSERT INTO comment (id, body, author, post_id, status) 
 			VALUES ($1, $2, $3, $4, $5)`
-		_, err = postgres.db.Exec(query, comment.Id, comment.Body, comment.Author, comment.PostId, "Published")
+		_, err = tx.Exec(query, comment.Id, comment.Body, comment.Author, comment.PostId, "Published")
+		err = checkPostgresErr(err)
+		if err != nil {
+			return err
+		}
 	} else {
+		// The reply is not created if the author of the parent comment has blocked the author of the reply
 		query := `
 			INSERT INTO comment (id, body, author, post_id, status, parent_id, parent_author, parent_body) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
-		_, err = postgres.db.Exec(query, comment.Id, comment.Body, comment.Author, comment.PostId, 
+			SELECT $1::uuid, $2, $3, $4::uuid, $5::status, $6::uuid, $7, $8
+			WHERE NOT EXISTS (
+				SELECT 1 FROM comment AS parent
+				JOIN user_block ON user_block.blocker = parent.author
+				WHERE parent.id = $6 AND user_block.blocked = $3
+			)`
+		result, err := tx.Exec(query, comment.Id, comment.Body, comment.Author, comment.PostId, 
 								"Published", comment.ParentComment.Id, comment.ParentComment.Author, comment.ParentComment.Body)
+		err = checkPostgresErr(err)
+		if err != nil {
+			return err
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return httperror.NewInternalServerError(err)
+		}
+		if rowsAffected == 0 {
+			return BlockedReplyError
+		}
 	}
 
-	return checkPostgresErr(err)
+	err = enqueueWebhookEvent(tx, event)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction if all queries are successful
+	err = tx.Commit()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// The conditions used to select comments in GetComments. All fields are optional
+// If Highlight is set and there is a search query, snippets of the body with the matches marked are returned
+// instead of the body
+type CommentFilter struct {
+	PostId      string
+	Author      string
+	Statuses    []string
+	SearchQuery SearchQuery
+	LikedBy     string
+	SortBy      string
+	Highlight   bool
+	ListingFilter
 }
 
 // Get comments by post, author, search query, and who liked them (all optional)
 // and sort by either newest, mosts likes, or relevance (default is by relevance)
 // (relevance is by search query. If search query is empty, then sort by newest first)
-func (postgres *PostgresStore) GetComments(username string, postId string, author string, statuses []string, searchQuery string, likedBy string, sortBy string) ([]Comment, error) {
+func (postgres *PostgresStore) GetComments(username string, filter CommentFilter) ([]Comment, error) {
+	conditionCount := 2
+	conditions := []any{username}
+
+	// When highlighting, the body is replaced by snippets so that large bodies are not sent
+	bodyColumn := "c.body"
+	highlightColumn := "NULL"
+	highlight := filter.Highlight && filter.SearchQuery.HasText()
+	if highlight {
+		bodyColumn = "''"
+		highlightColumn = headlineColumn("c.body", fmt.Sprintf("websearch_to_tsquery('english', $%v)", conditionCount), bodyHeadlineOptions)
+		conditions = append(conditions, filter.SearchQuery.Text)
+		conditionCount += 1
+	}
 
 	// select the user's vote and parent_comment's author and body too because the frontend needs it
-	// In the subquery, group by must be applied to all 3 fields in order to select comment_vote.vote for each user
-	query := `SELECT c.id, c.body, c.author, c.post_id, c.status,
-					  c.parent_id, c.parent_author, c.parent_body,
-					  c_votes.likes, c_votes.dislikes,
-					  c.created_at, c.updated_at,
-					  comment_vote.vote
-			  FROM (
-			  		SELECT comment.id AS id, 
-							COUNT(case when comment_vote.vote = 'Like' then 1 else null end) AS likes,
-					  		COUNT(case when comment_vote.vote = 'Dislike' then 1 else null end) AS dislikes
-			   		FROM comment
-					LEFT JOIN comment_vote ON comment.id = comment_vote.comment_id
-			   		GROUP BY id
-			   ) AS c_votes
-			  INNER JOIN comment AS c ON c.id = c_votes.id
+	// The likes and dislikes are counters on the comment itself, so votes do not need to be aggregated
+	// Replies to blocked users are kept, but the quoted body of the blocked user's comment is removed
+	query := fmt.Sprintf(`SELECT c.id, %s, c.author, c.post_id, c.status,
+					  c.parent_id, c.parent_author, CASE WHEN %s THEN '' ELSE c.parent_body END,
+					  c.likes, c.dislikes,
+					  c.created_at, c.updated_at, c.version,
+					  comment_vote.vote, %s
+			  FROM comment AS c
 			  LEFT JOIN comment_vote ON c.id = comment_vote.comment_id 
 					AND comment_vote.viewer = $1			  
-			  WHERE 1 = 1`
+			  WHERE 1 = 1`, bodyColumn, isBlockedBy("c.parent_author", "$1"), highlightColumn)
+
+	// The comments of shadow-banned users are only shown to themselves, and the comments of blocked users are hidden
+	query = query + " AND " + notShadowBanned("c.author", "$1") + " AND NOT " + isBlockedBy("c.author", "$1")
 
 	// Append conditions to the query based on the arguments provided
-	conditionCount := 2
-	conditions := []any{username}
-	if postId != "" {
+	if filter.PostId != "" {
 		query = query + fmt.Sprintf(" AND c.post_id = $%v", conditionCount)
-		conditions = append(conditions, postId)
+		conditions = append(conditions, filter.PostId)
 		conditionCount += 1
 	}
-	if author != "" {
+	if filter.Author != "" {
 		query = query + fmt.Sprintf(" AND c.author = $%v", conditionCount)
-		conditions = append(conditions, author)
+		conditions = append(conditions, filter.Author)
 		conditionCount += 1
 	}
-	if (len(statuses) > 0) {
+	if (len(filter.Statuses) > 0) {
 		query = query + fmt.Sprintf(" AND c.status = ANY($%v)", conditionCount)
-		conditions = append(conditions, pq.Array(statuses))
+		conditions = append(conditions, pq.Array(filter.Statuses))
 		conditionCount += 1
 	}	
-	if strings.Trim(searchQuery, " ") != "" {
-		query = query + fmt.Sprintf(" AND c.textsearchable_index @@ plainto_tsquery($%v)", conditionCount)
-		conditions = append(conditions, searchQuery)
-		conditionCount += 1
-	}
-	if likedBy != "" {
-		query = query + fmt.Sprintf(" AND comment_vote.viewer = $%v AND comment_vote.vote = 'Like'", conditionCount)
-		conditions = append(conditions, likedBy)
+	query, conditions, conditionCount = appendSearchQueryConditions(query, conditions, conditionCount, filter.SearchQuery, commentSearchColumns)
+	query, conditions, conditionCount = appendListingFilterConditions(query, conditions, conditionCount, filter.ListingFilter, commentListingColumns)
+	if filter.LikedBy != "" {
+		// Not matched against the joined vote, which is the viewer's rather than the given user's
+		query = query + fmt.Sprintf(" AND EXISTS (SELECT 1 FROM comment_vote AS v WHERE v.comment_id = c.id AND v.viewer = $%v AND v.vote = 'Like')", conditionCount)
+		conditions = append(conditions, filter.LikedBy)
 		conditionCount += 1
 	}
 
 	// Append the corresponding "order by" statement
-	switch sortBy {
+	switch filter.SortBy {
 	case "Newest":
 		query = query + " ORDER BY c.created_at DESC"
 	case "Oldest":
 		query = query + " ORDER BY c.created_at ASC"
 	case "Popular":
-		query = query + " ORDER BY c_votes.likes - c_votes.dislikes DESC"
+		query = query + " ORDER BY c.likes - c.dislikes DESC"
 	case "Relevance":
 		// If search query is empty, sort by tag relevance
 		// If tags are empty, sort by newest first
-		if searchQuery != "" {
-			query = query + fmt.Sprintf(` ORDER BY ts_rank_cd(c.textsearchable_index, plainto_tsquery($%v)) DESC`, conditionCount)
-			conditions = append(conditions, searchQuery)
+		if filter.SearchQuery.HasText() {
+			query = query + fmt.Sprintf(` ORDER BY ts_rank_cd(c.textsearchable_index, websearch_to_tsquery('english', $%v)) DESC`, conditionCount)
+			conditions = append(conditions, filter.SearchQuery.Text)
 			conditionCount += 1
 		} else {
 			query = query + " ORDER BY c.created_at DESC"
@@ -139,11 +209,12 @@ func (postgres *PostgresStore) GetComments(username string, postId string, autho
 		var parentAuthor sql.NullString
 		var parentBody sql.NullString
 		var userVote sql.NullString
+		var bodyHighlight sql.NullString
 
 		err := rows.Scan(
 			&comment.Id, &comment.Body, &comment.Author, &comment.PostId,
 			&comment.Status, &parentId, &parentAuthor, &parentBody,
-			&comment.Likes, &comment.Dislikes, &comment.CreatedAt, &comment.UpdatedAt, &userVote)
+			&comment.Likes, &comment.Dislikes, &comment.CreatedAt, &comment.UpdatedAt, &comment.Version, &userVote, &bodyHighlight)
 
 		err = checkPostgresErr(err)
 		if err != nil {
@@ -161,6 +232,9 @@ func (postgres *PostgresStore) GetComments(username string, postId string, autho
 			}
 
 			comment.UserVote = userVote.String
+			if highlight {
+				comment.Highlight = &CommentHighlight{Body: markHighlights(bodyHighlight.String)}
+			}
 
 			comments = append(comments, comment)
 		}
@@ -169,7 +243,25 @@ func (postgres *PostgresStore) GetComments(username string, postId string, autho
 	return comments, nil
 }
 
-func (postgres *PostgresStore) UpdateComment(comment Comment) error {
+// Gets the author of the comment, or "" if the comment does not exist
+func (postgres *PostgresStore) GetCommentAuthor(commentId string) (string, error) {
+	var author string
+	query := `SELECT author FROM comment WHERE id = $1`
+	err := postgres.db.QueryRow(query, commentId).Scan(&author)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return "", err
+	}
+	return author, nil
+}
+
+// Updates the comment if it is still at comment.Version (a version of 0 skips the check), and returns its new version
+// If the comment has been changed since, a StaleVersionError is returned along with the current version
+func (postgres *PostgresStore) UpdateComment(comment Comment) (int, error) {
 	var parentId sql.NullString
 	var parentAuthor sql.NullString
 	var parentBody sql.NullString
@@ -195,35 +287,154 @@ func (postgres *PostgresStore) UpdateComment(comment Comment) error {
 		}				
 	}
 
+	var version int
 	query := `
-		UPDATE comment SET body = $1, post_id = $2, parent_id = $3, parent_author = $4, parent_body = $5, updated_at = $6 
-		WHERE id = $7`
-	_, err := postgres.db.Exec(query, comment.Body, comment.PostId, parentId, parentAuthor, parentBody, time.Now(), comment.Id)
-	return checkPostgresErr(err)
+		UPDATE comment SET body = $1, post_id = $2, parent_id = $3, parent_author = $4, parent_body = $5, updated_at = $6,
+			version = version + 1
+		WHERE id = $7 AND ($8 = 0 OR version = $8)
+		RETURNING version`
+	err := postgres.db.QueryRow(query, comment.Body, comment.PostId, parentId, parentAuthor, parentBody, time.Now(),
+		comment.Id, comment.Version).Scan(&version)
+	if err == sql.ErrNoRows {
+		return getStaleVersion(postgres.db, "comment", comment.Id)
+	}
+	err = checkPostgresErr(err)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
 }
 
 func (postgres *PostgresStore) SoftDeleteComment(commentId string) error {
 	// Set the status to 'deleted' and clear the body
 	query := `
-		UPDATE comment SET body = '', status = 'Deleted', updated_at = $1 
+		UPDATE comment SET body = '', status = 'Deleted', updated_at = $1, version = version + 1
 		WHERE id = $2`
 	_, err := postgres.db.Exec(query, time.Now(), commentId)
 	return checkPostgresErr(err)
 }
 
 func (postgres *PostgresStore) UpsertCommentVote(commentVote CommentVote) error {
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	previousVote, err := lockCommentVote(tx, commentVote)
+	if err != nil {
+		return err
+	}
+
 	// Create the vote or update it if it already exists
 	query := `
 		INSERT INTO comment_vote (viewer, comment_id, vote) VALUES ($1, $2, $3)
 		ON CONFLICT(viewer, comment_id) DO UPDATE SET vote = $3`
-	_, err := postgres.db.Exec(query, commentVote.Viewer, commentVote.CommentId, commentVote.Vote)
-	return checkPostgresErr(err)
+	_, err = tx.Exec(query, commentVote.Viewer, commentVote.CommentId, commentVote.Vote)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	err = updateCommentVoteCounters(tx, commentVote.CommentId, previousVote, commentVote.Vote)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction if all queries are successful
+	err = tx.Commit()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+
+	return nil
 }
 
 func (postgres *PostgresStore) DeleteCommentVote(commentVote CommentVote) error {
-	// Create the vote or update it if it already exists
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	previousVote, err := lockCommentVote(tx, commentVote)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		DELETE FROM comment_vote WHERE viewer = $1 AND comment_id = $2`
-	_, err := postgres.db.Exec(query, commentVote.Viewer, commentVote.CommentId)
+	_, err = tx.Exec(query, commentVote.Viewer, commentVote.CommentId)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	err = updateCommentVoteCounters(tx, commentVote.CommentId, previousVote, "")
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction if all queries are successful
+	err = tx.Commit()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// Locks the comment so that concurrent votes on it are serialised, and returns the viewer's current vote ("" if none)
+func lockCommentVote(tx *sql.Tx, commentVote CommentVote) (string, error) {
+	query := `SELECT id FROM comment WHERE id = $1 FOR UPDATE`
+	_, err := tx.Exec(query, commentVote.CommentId)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return "", err
+	}
+
+	var previousVote sql.NullString
+	query = `SELECT vote FROM comment_vote WHERE viewer = $1 AND comment_id = $2`
+	err = tx.QueryRow(query, commentVote.Viewer, commentVote.CommentId).Scan(&previousVote)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return "", err
+	}
+
+	return previousVote.String, nil
+}
+
+func updateCommentVoteCounters(tx *sql.Tx, commentId string, previousVote string, currentVote string) error {
+	likesChange, dislikesChange := getVoteCounterChanges(previousVote, currentVote)
+	if likesChange == 0 && dislikesChange == 0 {
+		return nil
+	}
+
+	query := `UPDATE comment SET likes = likes + $1, dislikes = dislikes + $2 WHERE id = $3`
+	_, err := tx.Exec(query, likesChange, dislikesChange, commentId)
 	return checkPostgresErr(err)
 }
+
+func (postgres *PostgresStore) GetCommentVoteCount(commentId string) (*CommentVoteCount, error) {
+	voteCount := CommentVoteCount{CommentId: commentId}
+
+	// The post id is selected too so the vote count can be sent to clients watching the post
+	query := `SELECT post_id, likes, dislikes FROM comment WHERE id = $1`
+	err := postgres.db.QueryRow(query, commentId).Scan(&voteCount.PostId, &voteCount.Likes, &voteCount.Dislikes)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &voteCount, nil
+}
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestCommentsLikedByAnotherUser(t *testing.T) {
+	store := newTestStore(t)
+	viewer := "Jerry_the_mouse"
+	liker := "Tom_the_cat"
+
+	postId := createTestPost(t, store, viewer, "Published")
+	comment := Comment{Id: uuid.NewString(), Body: "Liked by someone else", Author: viewer, PostId: postId, Status: "Published"}
+	err := store.CreateComment(comment, nil)
+	if err != nil {
+		t.Fatalf("could not create the comment: %s", err)
+	}
+	t.Cleanup(func() {
+		store.db.Exec("DELETE FROM comment_vote WHERE comment_id = $1", comment.Id)
+		store.db.Exec("DELETE FROM comment WHERE id = $1", comment.Id)
+	})
+
+	err = store.UpsertCommentVote(CommentVote{Viewer: liker, CommentId: comment.Id, Vote: "Like"})
+	if err != nil {
+		t.Fatalf("could not like the comment: %s", err)
+	}
+
+	// The viewer has not voted, so the liker's vote must be found without the viewer's vote
+	comments, err := store.GetComments(viewer, CommentFilter{PostId: postId, LikedBy: liker})
+	if err != nil {
+		t.Fatalf("could not get the comments: %s", err)
+	}
+	if len(comments) != 1 || comments[0].Id != comment.Id {
+		t.Errorf("got %d comments liked by %s, want the comment %s", len(comments), liker, comment.Id)
+	}
+
+	comments, err = store.GetComments(liker, CommentFilter{PostId: postId, LikedBy: viewer})
+	if err != nil {
+		t.Fatalf("could not get the comments: %s", err)
+	}
+	if len(comments) != 0 {
+		t.Errorf("got %d comments liked by %s, who has not liked any", len(comments), viewer)
+	}
+}
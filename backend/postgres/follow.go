@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"backend/httperror"
+)
+
+type UserFollow struct {
+	Follower string
+	Followee string
+}
+
+type TagFollow struct {
+	Follower string
+	Tag      string
+}
+
+// The users and tags that a user follows
+type Following struct {
+	Users []string `json:"users"`
+	Tags  []string `json:"tags"`
+}
+
+type FollowerCount struct {
+	Followers int `json:"followers"`
+}
+
+func (postgres *PostgresStore) UpsertUserFollow(userFollow UserFollow) error {
+	// Create the follow or do nothing if it already exists
+	query := `
+		INSERT INTO user_follow (follower, followee) VALUES ($1, $2)
+		ON CONFLICT(follower, followee) DO NOTHING`
+	_, err := postgres.db.Exec(query, userFollow.Follower, userFollow.Followee)
+	return checkPostgresErr(err)
+}
+
+func (postgres *PostgresStore) DeleteUserFollow(userFollow UserFollow) error {
+	query := `
+		DELETE FROM user_follow WHERE follower = $1 AND followee = $2`
+	_, err := postgres.db.Exec(query, userFollow.Follower, userFollow.Followee)
+	return checkPostgresErr(err)
+}
+
+func (postgres *PostgresStore) UpsertTagFollow(tagFollow TagFollow) error {
+	// Create the follow or do nothing if it already exists
+	// Tags are followed case-insensitively, which is enforced by a unique index on the lowercased tag
+	query := `
+		INSERT INTO tag_follow (follower, tag) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+	_, err := postgres.db.Exec(query, tagFollow.Follower, tagFollow.Tag)
+	return checkPostgresErr(err)
+}
+
+func (postgres *PostgresStore) DeleteTagFollow(tagFollow TagFollow) error {
+	query := `
+		DELETE FROM tag_follow WHERE follower = $1 AND lower(tag) = lower($2)`
+	_, err := postgres.db.Exec(query, tagFollow.Follower, tagFollow.Tag)
+	return checkPostgresErr(err)
+}
+
+func (postgres *PostgresStore) GetFollowing(username string) (*Following, error) {
+	following := Following{
+		Users: []string{},
+		Tags:  []string{},
+	}
+
+	query := `SELECT followee FROM user_follow WHERE follower = $1 ORDER BY followee`
+	rows, err := postgres.db.Query(query, username)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var followee string
+
+		err := rows.Scan(&followee)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		} else {
+			following.Users = append(following.Users, followee)
+		}
+	}
+
+	query = `SELECT tag FROM tag_follow WHERE follower = $1 ORDER BY tag`
+	tagRows, err := postgres.db.Query(query, username)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var tag string
+
+		err := tagRows.Scan(&tag)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		} else {
+			following.Tags = append(following.Tags, tag)
+		}
+	}
+
+	return &following, nil
+}
+
+func (postgres *PostgresStore) GetUserFollowerCount(username string) (*FollowerCount, error) {
+	var followerCount FollowerCount
+
+	query := `SELECT COUNT(*) FROM user_follow WHERE followee = $1`
+	err := postgres.db.QueryRow(query, username).Scan(&followerCount.Followers)
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &followerCount, nil
+}
+
+func (postgres *PostgresStore) GetTagFollowerCount(tag string) (*FollowerCount, error) {
+	var followerCount FollowerCount
+
+	query := `SELECT COUNT(*) FROM tag_follow WHERE lower(tag) = lower($1)`
+	err := postgres.db.QueryRow(query, tag).Scan(&followerCount.Followers)
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &followerCount, nil
+}
@@ -21,7 +21,16 @@ type Post struct {
 	Dislikes  int `json:"dislikes"`
 	CreatedAt string `json:"createdAt"`
 	UpdatedAt string `json:"updatedAt"`
+	Version   int `json:"version"`
 	UserVote      string   `json:"userVote"`	
+	IsBookmarked  bool     `json:"isBookmarked"`
+	Highlight     *PostHighlight `json:"highlight,omitempty"`
+}
+
+// HTML escaped snippets of a post that match a search query, with the matches wrapped in <mark> tags
+type PostHighlight struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
 }
 
 type PostVote struct {
@@ -30,7 +39,57 @@ type PostVote struct {
 	Vote string
 }
 
-func (postgres *PostgresStore) CreatePost(post Post) error {
+// The conditions used to select posts in GetPosts. All fields are optional
+// Statuses can include "Draft", but only the viewer's own drafts are selected
+// FeedOf selects the posts with the tags or authors followed by the given user
+// Period (day, week, month or year) restricts the "Top" sort to posts created within the period. It defaults to a day
+// If Highlight is set and there is a search query, snippets of the title and body with the matches marked are returned
+// instead of the body
+// Limit and Offset are used for pagination. A Limit of 0 means no limit
+type PostFilter struct {
+	Author       string
+	Statuses     []string
+	SearchQuery  SearchQuery
+	Tags         []string
+	LikedBy      string
+	BookmarkedBy string
+	FeedOf       string
+	SortBy       string
+	Period       string
+	Highlight    bool
+	ListingFilter
+	Limit        int
+	Offset       int
+}
+
+// The intervals of the periods that the "Top" sort can be restricted to
+var topPeriodIntervals = map[string]string{
+	"":      "1 day",
+	"day":   "1 day",
+	"week":  "7 days",
+	"month": "1 month",
+	"year":  "1 year",
+}
+
+// The half-life of a vote's weight in the hot score. A vote's weight halves every day after it was cast
+const hotScoreVoteHalfLife = 24 * time.Hour
+
+// Every 45000 seconds (12.5 hours) of a post's age is worth as much as a 10-fold increase in its net votes
+const hotScoreAgeDivisor = 45000
+
+type PostBookmark struct {
+	Viewer string
+	PostId string
+}
+
+type PostVoteCount struct {
+	PostId   string `json:"postId"`
+	Likes    int    `json:"likes"`
+	Dislikes int    `json:"dislikes"`
+}
+
+// The webhook event (optional) is enqueued in the same transaction
+func (postgres *PostgresStore) CreatePost(post Post, event *WebhookEvent) error {
 	tx, err := postgres.db.Begin()
 	if err != nil {
 		return httperror.NewInternalServerError(err)
@@ -39,24 +98,23 @@ func (postgres *PostgresStore) CreatePost(post Post) error {
 
 	// Create a row in the post table
 	query := `
-		INSERT INTO post (id, title, body, author, status) 
-		VALUES ($1, $2, $3, $4, $5)`
-	_, err = tx.Exec(query, post.Id, post.Title, post.Body, post.Author, post.Status)
+		INSERT INTO post (id, title, body, author, status, hot_score) 
+		VALUES ($1, $2, $3, $4, $5, extract(epoch from now()) / $6)`
+	_, err = tx.Exec(query, post.Id, post.Title, post.Body, post.Author, post.Status, hotScoreAgeDivisor)
 	err = checkPostgresErr(err)
 	if err != nil {
 		return err
 	}
 
 	// Create the post-tag mappings
-	for i := 0; i < len(post.Tags); i++ {
-		query := `
-			INSERT INTO post_tag (post_id, tag) 
-			VALUES ($1, $2)`
-		_, err = tx.Exec(query, post.Id, post.Tags[i])
-		err = checkPostgresErr(err)
-		if err != nil {
-			return err
-		}
+	err = insertPostTags(tx, post.Id, post.Tags, nil)
+	if err != nil {
+		return err
+	}
+
+	err = enqueueWebhookEvent(tx, event)
+	if err != nil {
+		return err
 	}
 
 	// Commit the transaction if all queries are successful
@@ -72,29 +130,24 @@ func (postgres *PostgresStore) GetPostById(username string, postId string) (*Pos
 	var post Post
 	var userVote sql.NullString
 
-	// Use a subquery to aggregate the tags, likes, and dislikes
-	// Then join the result with the post table to get the other details of the posts
-	// Note 1: Left join is used for both joins as a post may not have any tags nor votes
-	query := `SELECT post.id, post.author, post.title, post.body, p.tags, post.status,
-					  p.likes, p.dislikes,
-					  post.created_at, post.updated_at, post_vote.vote
-			  FROM (
-			  		SELECT post.id AS id, 
-							array_remove(array_agg(DISTINCT post_tag.tag), NULL) AS tags, 
-							COUNT(case when post_vote.vote = 'Like' then 1 else null end) AS likes,
-					  		COUNT(case when post_vote.vote = 'Dislike' then 1 else null end) AS dislikes
-			   		FROM post
-			   		LEFT JOIN post_tag ON post.id = post_tag.post_id
-					LEFT JOIN post_vote ON post.id = post_vote.post_id
-			   		WHERE post.id = $1
-			   		GROUP BY id
-			   ) AS p				
-			   INNER JOIN post ON post.id = p.id
-			   LEFT JOIN post_vote ON p.id = post_vote.post_id 
-			   	    AND post_vote.viewer = $2`
+	// Use a subquery to aggregate the tags. The likes and dislikes are counters on the post itself
+	// Note 1: Left join is used for the user's vote as the user may not have voted on the post
+	// Note 2: The user's bookmark is left joined too as the user may not have bookmarked the post
+	// Note 3: The posts of shadow-banned users are only found by themselves
+	query := `SELECT post.id, post.author, post.title, post.body,
+					  ARRAY(SELECT tag FROM post_tag WHERE post_tag.post_id = post.id ORDER BY tag),
+					  post.status, post.likes, post.dislikes,
+					  post.created_at, post.updated_at, post.version, post_vote.vote,
+					  post_bookmark.post_id IS NOT NULL
+			  FROM post
+			  LEFT JOIN post_vote ON post.id = post_vote.post_id 
+			   	    AND post_vote.viewer = $2
+			  LEFT JOIN post_bookmark ON post.id = post_bookmark.post_id
+			   	    AND post_bookmark.viewer = $2
+			  WHERE post.id = $1 AND ` + notShadowBanned("post.author", "$2")
 	err := postgres.db.QueryRow(query, postId, username).Scan(
 			&post.Id, &post.Author, &post.Title, &post.Body, pq.Array(&post.Tags), &post.Status, 
-			&post.Likes, &post.Dislikes, &post.CreatedAt, &post.UpdatedAt, &userVote)
+			&post.Likes, &post.Dislikes, &post.CreatedAt, &post.UpdatedAt, &post.Version, &userVote, &post.IsBookmarked)
 	
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -109,92 +162,141 @@ func (postgres *PostgresStore) GetPostById(username string, postId string) (*Pos
 	}
 }
 
-// Get posts by author, status, search query, tags, and who liked them (all optional) 
+// Gets the author of the post, or "" if the post does not exist
+func (postgres *PostgresStore) GetPostAuthor(postId string) (string, error) {
+	var author string
+	query := `SELECT author FROM post WHERE id = $1`
+	err := postgres.db.QueryRow(query, postId).Scan(&author)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return "", err
+	}
+	return author, nil
+}
+
+// Get posts by author, status, search query, tags, who liked them, who bookmarked them, 
+// and whose feed they belong to (all optional)
 // and sort by either newest, mosts likes, or relevance (default is by relevance)
 // (relevance is by search query. If search query is empty, then relevance is by tag)
-func (postgres *PostgresStore) GetPosts(username string, author string, statuses []string, searchQuery string, tags []string, likedBy string, sortBy string) ([]Post, error) {
+func (postgres *PostgresStore) GetPosts(username string, filter PostFilter) ([]Post, error) {
 	
-	// Use a subquery to aggregate the tags, likes, dislikes, and the user's vote
-	// Then join the result with the post table to get the other details of the posts
-	// Note 1: In the subquery, group by must be applied to all 3 fields in order to select 
-	//         post_vote.vote for each user
-	// Note 2: In the subquery, outer join is used because the user may not have voted on the post
-	// Note 2: Left join is used for both joins as a post may not have any tags nor votes
-	// Note 3: An intermediate column called "tags_lowercased" is created to enable 
+	// Use a lateral subquery to aggregate the tags of each post
+	// The likes and dislikes are counters on the post itself, so votes do not need to be aggregated
+	// Note 1: Left join is used for the user's vote and bookmark as the user may not have voted on/bookmarked the post
+	// Note 2: An intermediate column called "tags_lowercased" is created to enable 
 	//         case-insensitive filtering by tags. It is supported by an index
 
-	query := `SELECT post.id, post.author, post.title, post.body, p.tags, post.status,
-						p.likes, p.dislikes,
-						post.created_at, post.updated_at,
-						post_vote.vote
-			  FROM (
-			  		SELECT post.id AS id, 
-							array_remove(array_agg(DISTINCT post_tag.tag), NULL) AS tags,
-							array_remove(array_agg(DISTINCT lower(post_tag.tag)), NULL) AS tags_lowercased, 
-							COUNT(case when post_vote.vote = 'Like' then 1 else null end) AS likes,
-					  		COUNT(case when post_vote.vote = 'Dislike' then 1 else null end) AS dislikes,
-							array_agg(post_vote.viewer) filter (WHERE post_vote.vote = 'Like') AS likers
-			   		FROM post
-			   		LEFT JOIN post_tag ON post.id = post_tag.post_id
-					LEFT JOIN post_vote ON post.id= post_vote.post_id				
-			   		GROUP BY id
-			   ) AS p
-			   INNER JOIN post ON post.id = p.id
-			   LEFT JOIN post_vote ON p.id = post_vote.post_id 
-			   	    AND post_vote.viewer = $1			   
-			   WHERE 1 = 1`
-
-	// Append conditions to the query based on the arguments provided
 	conditionCount := 2
 	conditions := []any{username}
-	if (author != "") {
-		query = query + fmt.Sprintf(" AND post.author = $%v", conditionCount)
-		conditions = append(conditions, author)
+
+	// When highlighting, the body is replaced by snippets so that large bodies are not sent
+	bodyColumn := "post.body"
+	highlightColumns := "NULL, NULL"
+	highlight := filter.Highlight && filter.SearchQuery.HasText()
+	if highlight {
+		bodyColumn = "''"
+		tsquery := fmt.Sprintf("websearch_to_tsquery('english', $%v)", conditionCount)
+		highlightColumns = headlineColumn("post.title", tsquery, titleHeadlineOptions) + ", " +
+			headlineColumn("post.body", tsquery, bodyHeadlineOptions)
+		conditions = append(conditions, filter.SearchQuery.Text)
 		conditionCount += 1
 	}
-	if (len(statuses) > 0) {
-		query = query + fmt.Sprintf(" AND post.status = ANY($%v)", conditionCount)
-		conditions = append(conditions, pq.Array(statuses))
+
+	query := fmt.Sprintf(`SELECT post.id, post.author, post.title, %s, p.tags, post.status,
+						post.likes, post.dislikes,
+						post.created_at, post.updated_at, post.version,
+						post_vote.vote, post_bookmark.post_id IS NOT NULL, %s
+			  FROM post
+			  LEFT JOIN LATERAL (
+			  		SELECT COALESCE(array_agg(post_tag.tag ORDER BY post_tag.tag), '{}') AS tags,
+							COALESCE(array_agg(lower(post_tag.tag)), '{}') AS tags_lowercased
+			   		FROM post_tag
+			   		WHERE post_tag.post_id = post.id
+			  ) AS p ON true
+			  LEFT JOIN post_vote ON post.id = post_vote.post_id 
+			   	    AND post_vote.viewer = $1
+			  LEFT JOIN post_bookmark ON post.id = post_bookmark.post_id
+			   	    AND post_bookmark.viewer = $1
+			  WHERE 1 = 1`, bodyColumn, highlightColumns)
+
+	// The posts of shadow-banned users are only shown to themselves, and the posts of blocked users are hidden
+	query = query + " AND " + notShadowBanned("post.author", "$1") + " AND NOT " + isBlockedBy("post.author", "$1")
+
+	// Append conditions to the query based on the arguments provided
+	if (filter.Author != "") {
+		query = query + fmt.Sprintf(" AND post.author = $%v", conditionCount)
+		conditions = append(conditions, filter.Author)
 		conditionCount += 1
 	}
-	if (strings.Trim(searchQuery, " ") != "") {
-		query = query + fmt.Sprintf(" AND post.textsearchable_index @@ plainto_tsquery($%v)", conditionCount)
-		conditions = append(conditions, searchQuery)
+	if (len(filter.Statuses) > 0) {
+		// Drafts are only ever selected for their author
+		query = query + fmt.Sprintf(" AND post.status = ANY($%v) AND (post.status <> 'Draft' OR post.author = $1)", conditionCount)
+		conditions = append(conditions, pq.Array(filter.Statuses))
 		conditionCount += 1
 	}
-	if (len(tags) > 0) {
-		query = query + fmt.Sprintf(" AND p.tags_lowercased && $%v", conditionCount)
+	query, conditions, conditionCount = appendSearchQueryConditions(query, conditions, conditionCount, filter.SearchQuery, postSearchColumns)
+	query, conditions, conditionCount = appendListingFilterConditions(query, conditions, conditionCount, filter.ListingFilter, postListingColumns)
+	if (len(filter.Tags) > 0) {
+		// Synonyms and descendants of the tags match too
+		query = query + fmt.Sprintf(" AND p.tags_lowercased && %s", expandTags(fmt.Sprintf("$%v", conditionCount)))
 
 		// Lowercase all tags to enable case-insensitive search
-		for i := 0; i < len(tags); i += 1 {
-			tags[i] = strings.ToLower(tags[i])
+		for i := 0; i < len(filter.Tags); i += 1 {
+			filter.Tags[i] = strings.ToLower(filter.Tags[i])
 		}
 
-		conditions = append(conditions, pq.Array(tags))
+		conditions = append(conditions, pq.Array(filter.Tags))
 		conditionCount += 1		
 	}
-	if (likedBy != "") {
-		query = query + fmt.Sprintf(" AND $%v = ANY(p.likers)", conditionCount)
-		conditions = append(conditions, likedBy)
+	if (filter.LikedBy != "") {
+		query = query + fmt.Sprintf(" AND EXISTS (SELECT 1 FROM post_vote AS v WHERE v.post_id = post.id AND v.viewer = $%v AND v.vote = 'Like')", conditionCount)
+		conditions = append(conditions, filter.LikedBy)
+		conditionCount += 1
+	}
+	if (filter.BookmarkedBy != "") {
+		query = query + fmt.Sprintf(" AND EXISTS (SELECT 1 FROM post_bookmark AS b WHERE b.post_id = post.id AND b.viewer = $%v)", conditionCount)
+		conditions = append(conditions, filter.BookmarkedBy)
+		conditionCount += 1
+	}
+	if (filter.FeedOf != "") {
+		// A post belongs to the feed if its author or one of its tags is followed
+		query = query + fmt.Sprintf(` AND (post.author IN (SELECT followee FROM user_follow WHERE follower = $%v)
+									 OR p.tags_lowercased && %s)`,
+									 conditionCount, expandTags(fmt.Sprintf("ARRAY(SELECT lower(tag) FROM tag_follow WHERE follower = $%v)::text[]", conditionCount)))
+		conditions = append(conditions, filter.FeedOf)
 		conditionCount += 1
 	}
 
 	// Append the corresponding "order by" statement
-	switch sortBy {
+	switch filter.SortBy {
 	case "Newest":
 		query = query + " ORDER BY post.created_at DESC"
 	case "Popular":
-		query = query + " ORDER BY p.likes - p.dislikes DESC"
+		query = query + " ORDER BY post.likes - post.dislikes DESC"
+	case "Hot":
+		// The hot score is precomputed by RefreshHotScores
+		query = query + " ORDER BY post.hot_score DESC"
+	case "Top":
+		query = query + fmt.Sprintf(" AND post.created_at >= now() - $%v::interval ORDER BY post.likes - post.dislikes DESC", conditionCount)
+		conditions = append(conditions, topPeriodIntervals[filter.Period])
+		conditionCount += 1
 	case "Relevance":
 		// If search query is empty, sort by tag relevance
 		// If tags are empty, sort by newest first
-		if (searchQuery != "") {
-			query = query + fmt.Sprintf(` ORDER BY ts_rank_cd(post.textsearchable_index, plainto_tsquery($%v)) DESC`, conditionCount)
-			conditions = append(conditions, searchQuery)
+		if (filter.SearchQuery.HasText()) {
+			// Fuzzy matches of the title are ranked by similarity after the exact matches
+			query = query + fmt.Sprintf(` ORDER BY ts_rank_cd(post.textsearchable_index, websearch_to_tsquery('english', $%v)) DESC,
+										word_similarity($%v, post.title) DESC`, conditionCount, conditionCount)
+			conditions = append(conditions, filter.SearchQuery.Text)
 			conditionCount += 1
-		} else if (len(tags) > 0) {
-			query = query + fmt.Sprintf(` ORDER BY cardinality(ARRAY(SELECT * FROM UNNEST(p.tags) WHERE UNNEST = ANY($%v)))`, conditionCount)
-			conditions = append(conditions, pq.Array(tags))
+		} else if (len(filter.Tags) > 0) {
+			query = query + fmt.Sprintf(` ORDER BY cardinality(ARRAY(SELECT * FROM UNNEST(p.tags_lowercased) WHERE UNNEST = ANY(%s))) DESC`,
+										expandTags(fmt.Sprintf("$%v", conditionCount)))
+			conditions = append(conditions, pq.Array(filter.Tags))
 			conditionCount += 1
 		} else { 
 			query = query + " ORDER BY post.created_at DESC"
@@ -203,6 +305,15 @@ func (postgres *PostgresStore) GetPosts(username string, author string, statuses
 		query = query + " ORDER BY post.created_at DESC"
 	}
 
+	// The id is used as a tie breaker so that pages do not overlap
+	query = query + ", post.id"
+
+	if (filter.Limit > 0) {
+		query = query + fmt.Sprintf(" LIMIT $%v OFFSET $%v", conditionCount, conditionCount + 1)
+		conditions = append(conditions, filter.Limit, filter.Offset)
+		conditionCount += 2
+	}
+
 	// Execute the query
 	rows, err := postgres.db.Query(query, conditions...)
 	if err != nil {
@@ -215,16 +326,22 @@ func (postgres *PostgresStore) GetPosts(username string, author string, statuses
 	for rows.Next() {
 		var post Post
 		var userVote sql.NullString
+		var titleHighlight sql.NullString
+		var bodyHighlight sql.NullString
 
 		err := rows.Scan(
 			&post.Id, &post.Author, &post.Title, &post.Body, pq.Array(&post.Tags), &post.Status, 
-			&post.Likes, &post.Dislikes, &post.CreatedAt, &post.UpdatedAt, &userVote)
+			&post.Likes, &post.Dislikes, &post.CreatedAt, &post.UpdatedAt, &post.Version, &userVote, &post.IsBookmarked,
+			&titleHighlight, &bodyHighlight)
 
 		err = checkPostgresErr(err)
 		if (err != nil) {
 			return nil, err
 		} else {
 			post.UserVote = userVote.String
+			if highlight {
+				post.Highlight = &PostHighlight{Title: markHighlights(titleHighlight.String), Body: markHighlights(bodyHighlight.String)}
+			}
 			posts = append(posts, post)
 		}
 	}
@@ -233,92 +350,154 @@ func (postgres *PostgresStore) GetPosts(username string, author string, statuses
 }
 
 func updateTags(post Post, tx *sql.Tx) error {
+	// Retired tags that the post already has are kept, so that the post can still be edited
+	var currentTags []string
+	query := `SELECT COALESCE(array_agg(tag), '{}') FROM post_tag WHERE post_id = $1`
+	err := tx.QueryRow(query, post.Id).Scan(pq.Array(&currentTags))
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
 	// Delete the current post-tag mappings and create new ones
-	query := `DELETE FROM post_tag WHERE post_id = $1`
-	_, err := tx.Exec(query, post.Id)
+	query = `DELETE FROM post_tag WHERE post_id = $1`
+	_, err = tx.Exec(query, post.Id)
 	err = checkPostgresErr(err)
 	if err != nil {
 		return err
 	}	
 
-	for i := 0; i < len(post.Tags); i++ {
+	return insertPostTags(tx, post.Id, post.Tags, currentTags)
+}
+
+// Creates the post-tag mappings with the canonical names of the tags. Tags that differ only by case are merged
+// Retired tags are rejected unless they are in allowedRetiredTags
+func insertPostTags(tx *sql.Tx, postId string, tags []string, allowedRetiredTags []string) error {
+	insertedTags := map[string]bool{}
+
+	for i := 0; i < len(tags); i++ {
+		tag, err := resolveTag(tx, tags[i], allowedRetiredTags)
+		if err != nil {
+			return err
+		}
+		if insertedTags[tag] {
+			continue
+		}
+
 		query := `
 			INSERT INTO post_tag (post_id, tag) 
 			VALUES ($1, $2)`
-		_, err = tx.Exec(query, post.Id, post.Tags[i])
+		_, err = tx.Exec(query, postId, tag)
 		err = checkPostgresErr(err)
 		if err != nil {
 			return err
 		}
+		insertedTags[tag] = true
 	}
 
 	return nil
 }
 
-func (postgres *PostgresStore) UpdatePost(post Post) error {
+// Updates the post if it is still at post.Version (a version of 0 skips the check), and returns its new version
+// If the post has been changed since, a StaleVersionError is returned along with the current version
+func (postgres *PostgresStore) UpdatePost(post Post) (int, error) {
 	tx, err := postgres.db.Begin()
 	if err != nil {
-		return httperror.NewInternalServerError(err)
+		return 0, httperror.NewInternalServerError(err)
 	}
 	defer tx.Rollback()
 
 	// Update the existing row in the post table
+	var version int
 	query := `
-		UPDATE post SET title = $1, body = $2, updated_at = $3 
-		WHERE id = $4`
-	_, err = tx.Exec(query, post.Title, post.Body, time.Now(), post.Id)
+		UPDATE post SET title = $1, body = $2, updated_at = $3, version = version + 1
+		WHERE id = $4 AND ($5 = 0 OR version = $5)
+		RETURNING version`
+	err = tx.QueryRow(query, post.Title, post.Body, time.Now(), post.Id, post.Version).Scan(&version)
+	if err == sql.ErrNoRows {
+		return getStaleVersion(tx, "post", post.Id)
+	}
 	err = checkPostgresErr(err)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	err = updateTags(post, tx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	// Commit the transaction if all queries are successful
 	err = tx.Commit()
 	if err != nil {
-		return httperror.NewInternalServerError(err)
+		return 0, httperror.NewInternalServerError(err)
 	}
 
-	return nil
+	return version, nil
 }
 
-func (postgres *PostgresStore) UpdateDraftToPost(post Post) error {
+// Like UpdatePost, returns the new version, or 0 if the post does not exist
+// Returns a PostNotDraftError if the post has already been published (or deleted)
+// The webhook event (optional) is enqueued in the same transaction
+func (postgres *PostgresStore) UpdateDraftToPost(post Post, event *WebhookEvent) (int, error) {
 	tx, err := postgres.db.Begin()
 	if err != nil {
-		return httperror.NewInternalServerError(err)
+		return 0, httperror.NewInternalServerError(err)
 	}
 	defer tx.Rollback()
 
 	// Update the existing row in the post table
+	// The hot score is reset because the post's age starts from its publication, which can only happen once
+	var version int
 	query := `
-		UPDATE post SET title = $1, body = $2, status = 'Published', created_at = $3, updated_at = $4
-		WHERE id = $5`
+		UPDATE post SET title = $1, body = $2, status = 'Published', created_at = $3, updated_at = $4, 
+			hot_score = extract(epoch from $3::timestamptz) / $5, version = version + 1
+		WHERE id = $6 AND status = 'Draft' AND ($7 = 0 OR version = $7)
+		RETURNING version`
 	now := time.Now()
-	_, err = tx.Exec(query, post.Title, post.Body, now, now, post.Id)
+	err = tx.QueryRow(query, post.Title, post.Body, now, now, hotScoreAgeDivisor, post.Id, post.Version).Scan(&version)
+	if err == sql.ErrNoRows {
+		var status string
+		query = `SELECT status, version FROM post WHERE id = $1`
+		err = tx.QueryRow(query, post.Id).Scan(&status, &version)
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		err = checkPostgresErr(err)
+		if err != nil {
+			return 0, err
+		}
+		if status != "Draft" {
+			return version, PostNotDraftError
+		}
+		return version, NewStaleVersionError(version)
+	}
 	err = checkPostgresErr(err)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	
 	err = updateTags(post, tx)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	err = enqueueWebhookEvent(tx, event)
+	if err != nil {
+		return 0, err
 	}
 
 	// Commit the transaction if all queries are successful
 	err = tx.Commit()
 	if err != nil {
-		return httperror.NewInternalServerError(err)
+		return 0, httperror.NewInternalServerError(err)
 	}
 
-	return nil
+	return version, nil
 }
 
-func (postgres *PostgresStore) SoftDeletePost(postId string) error {
+// The webhook event (optional) is enqueued in the same transaction
+func (postgres *PostgresStore) SoftDeletePost(postId string, event *WebhookEvent) error {
 	tx, err := postgres.db.Begin()
 	if err != nil {
 		return httperror.NewInternalServerError(err)
@@ -327,7 +506,7 @@ func (postgres *PostgresStore) SoftDeletePost(postId string) error {
 
 	// Set the status to 'deleted' and clear the body (retain the title for reference)
 	query := `
-		UPDATE post SET title='', body = '', status = 'Deleted', updated_at = $1 
+		UPDATE post SET title='', body = '', status = 'Deleted', updated_at = $1, version = version + 1
 		WHERE id = $2`
 	_, err = tx.Exec(query, time.Now(), postId)
 	err = checkPostgresErr(err)
@@ -343,6 +522,11 @@ func (postgres *PostgresStore) SoftDeletePost(postId string) error {
 		return err
 	}	
 
+	err = enqueueWebhookEvent(tx, event)
+	if err != nil {
+		return err
+	}
+
 	// Commit the transaction if all queries are successful
 	err = tx.Commit()
 	if err != nil {
@@ -353,44 +537,194 @@ func (postgres *PostgresStore) SoftDeletePost(postId string) error {
 }
 
 func (postgres *PostgresStore) UpsertPostVote(postVote PostVote) error {
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	previousVote, err := lockPostVote(tx, postVote)
+	if err != nil {
+		return err
+	}
+
 	// Create the vote or update it if it already exists
+	// The time of the vote is recorded so that older votes count for less in the hot score
 	query := `
 		INSERT INTO post_vote (viewer, post_id, vote) VALUES ($1, $2, $3)
-		ON CONFLICT(viewer, post_id) DO UPDATE SET vote = $3`
-	_, err := postgres.db.Exec(query, postVote.Viewer, postVote.PostId, postVote.Vote)
-	return checkPostgresErr(err)
+		ON CONFLICT(viewer, post_id) DO UPDATE SET vote = $3, voted_at = now()`
+	_, err = tx.Exec(query, postVote.Viewer, postVote.PostId, postVote.Vote)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	err = updatePostVoteCounters(tx, postVote.PostId, previousVote, postVote.Vote)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction if all queries are successful
+	err = tx.Commit()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+
+	return nil
 }
 
 func (postgres *PostgresStore) DeletePostVote(postVote PostVote) error {
-	// Create the vote or update it if it already exists
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	previousVote, err := lockPostVote(tx, postVote)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		DELETE FROM post_vote WHERE viewer = $1 AND post_id = $2`
-	_, err := postgres.db.Exec(query, postVote.Viewer, postVote.PostId)
+	_, err = tx.Exec(query, postVote.Viewer, postVote.PostId)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	err = updatePostVoteCounters(tx, postVote.PostId, previousVote, "")
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction if all queries are successful
+	err = tx.Commit()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// Locks the post so that concurrent votes on it are serialised, and returns the viewer's current vote ("" if none)
+// Without the lock, two concurrent votes could both read the same previous vote and update the counters twice
+func lockPostVote(tx *sql.Tx, postVote PostVote) (string, error) {
+	query := `SELECT id FROM post WHERE id = $1 FOR UPDATE`
+	_, err := tx.Exec(query, postVote.PostId)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return "", err
+	}
+
+	var previousVote sql.NullString
+	query = `SELECT vote FROM post_vote WHERE viewer = $1 AND post_id = $2`
+	err = tx.QueryRow(query, postVote.Viewer, postVote.PostId).Scan(&previousVote)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return "", err
+	}
+
+	return previousVote.String, nil
+}
+
+func updatePostVoteCounters(tx *sql.Tx, postId string, previousVote string, currentVote string) error {
+	likesChange, dislikesChange := getVoteCounterChanges(previousVote, currentVote)
+	if likesChange == 0 && dislikesChange == 0 {
+		return nil
+	}
+
+	query := `UPDATE post SET likes = likes + $1, dislikes = dislikes + $2 WHERE id = $3`
+	_, err := tx.Exec(query, likesChange, dislikesChange, postId)
 	return checkPostgresErr(err)
 }
 
-func (postgres *PostgresStore) GetTags() ([]string, error) {
-	query := `SELECT DISTINCT tag FROM post_tag`
-	rows, err := postgres.db.Query(query)
+// Recalculates the hot scores of all published posts. Returns the number of posts whose score changed
+// The hot score is calculated Reddit-style: log10 of the net votes plus the post's age (in units of 12.5 hours).
+// Every vote is weighted by its age so that posts that stop receiving votes cool down
+func (postgres *PostgresStore) RefreshHotScores() (int64, error) {
+	query := `
+		UPDATE post SET hot_score = s.hot_score
+		FROM (
+			SELECT post.id,
+					sign(COALESCE(v.net_votes, 0)) * log(greatest(abs(COALESCE(v.net_votes, 0)), 1))
+					+ extract(epoch from post.created_at) / $1 AS hot_score
+			FROM post
+			LEFT JOIN (
+				SELECT post_id,
+						SUM(
+							(case when vote = 'Like' then 1 else -1 end)
+							* power(0.5, extract(epoch from now() - voted_at) / $2)
+						) AS net_votes
+				FROM post_vote
+				GROUP BY post_id
+			) AS v ON post.id = v.post_id
+			WHERE post.status = 'Published'
+		) AS s
+		WHERE post.id = s.id AND post.hot_score IS DISTINCT FROM s.hot_score`
+	result, err := postgres.db.Exec(query, hotScoreAgeDivisor, hotScoreVoteHalfLife.Seconds())
+	err = checkPostgresErr(err)
 	if err != nil {
-		return nil, httperror.NewInternalServerError(err)
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, httperror.NewInternalServerError(err)
 	}
-	defer rows.Close()
 
-	tags := []string{}
+	return rowsAffected, nil
+}
 
-	for rows.Next() {
-		var tag string
+// Only published posts and the viewer's own drafts can be bookmarked. Returns false if the post is not one of them
+func (postgres *PostgresStore) UpsertPostBookmark(postBookmark PostBookmark) (bool, error) {
+	// Create the bookmark or do nothing if it already exists
+	// The post is selected separately so that it is found even if the bookmark already exists
+	query := `
+		WITH bookmarkable AS (
+			SELECT id FROM post
+			WHERE id = $2 AND (status = 'Published' OR (status = 'Draft' AND author = $1))
+		), inserted AS (
+			INSERT INTO post_bookmark (viewer, post_id)
+			SELECT $1, id FROM bookmarkable
+			ON CONFLICT(viewer, post_id) DO NOTHING
+		)
+		SELECT EXISTS (SELECT 1 FROM bookmarkable)`
+	var found bool
+	err := postgres.db.QueryRow(query, postBookmark.Viewer, postBookmark.PostId).Scan(&found)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
 
-		err := rows.Scan(&tag)
+	return found, nil
+}
 
-		err = checkPostgresErr(err)
-		if (err != nil) {
-			return nil, err
-		} else {
-			tags = append(tags, tag)
-		}
+func (postgres *PostgresStore) DeletePostBookmark(postBookmark PostBookmark) error {
+	query := `
+		DELETE FROM post_bookmark WHERE viewer = $1 AND post_id = $2`
+	_, err := postgres.db.Exec(query, postBookmark.Viewer, postBookmark.PostId)
+	return checkPostgresErr(err)
+}
+
+func (postgres *PostgresStore) GetPostVoteCount(postId string) (*PostVoteCount, error) {
+	voteCount := PostVoteCount{PostId: postId}
+
+	query := `SELECT likes, dislikes FROM post WHERE id = $1`
+	err := postgres.db.QueryRow(query, postId).Scan(&voteCount.Likes, &voteCount.Dislikes)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
 
-	return tags, nil
-}
\ No newline at end of file
+	err = checkPostgresErr(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &voteCount, nil
+}
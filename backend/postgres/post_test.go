@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Connects to the database in TEST_DATABASE_URL, which must have been created from db_init_dev.sql
+// The test is skipped if it is not set
+func newTestStore(t *testing.T) *PostgresStore {
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	store, err := NewPostgresStore(connStr)
+	if err != nil {
+		t.Fatalf("could not connect to the test database: %s", err)
+	}
+	return store
+}
+
+// Creates a post and removes it (and its bookmarks) once the test is over
+func createTestPost(t *testing.T, store *PostgresStore, author string, status string) string {
+	post := Post{Id: uuid.NewString(), Title: "Bookmark test", Body: "Bookmark test", Author: author, Status: status}
+	err := store.CreatePost(post, nil)
+	if err != nil {
+		t.Fatalf("could not create the post: %s", err)
+	}
+
+	t.Cleanup(func() {
+		store.db.Exec("DELETE FROM post_bookmark WHERE post_id = $1", post.Id)
+		store.db.Exec("DELETE FROM post WHERE id = $1", post.Id)
+	})
+	return post.Id
+}
+
+func getTestBookmarkIds(t *testing.T, store *PostgresStore, viewer string) []string {
+	posts, err := store.GetPosts(viewer, PostFilter{BookmarkedBy: viewer, Statuses: []string{"Published", "Deleted", "Draft"}})
+	if err != nil {
+		t.Fatalf("could not get the bookmarks: %s", err)
+	}
+
+	ids := []string{}
+	for _, post := range posts {
+		ids = append(ids, post.Id)
+	}
+	return ids
+}
+
+func TestOwnDraftBookmarksAreListed(t *testing.T) {
+	store := newTestStore(t)
+	viewer := "Jerry_the_mouse"
+
+	publishedId := createTestPost(t, store, "Tom_the_cat", "Published")
+	ownDraftId := createTestPost(t, store, viewer, "Draft")
+	otherDraftId := createTestPost(t, store, "Tom_the_cat", "Draft")
+
+	for _, postId := range []string{publishedId, ownDraftId} {
+		found, err := store.UpsertPostBookmark(PostBookmark{Viewer: viewer, PostId: postId})
+		if err != nil || !found {
+			t.Fatalf("could not bookmark %s: found %t, error %v", postId, found, err)
+		}
+	}
+	found, err := store.UpsertPostBookmark(PostBookmark{Viewer: viewer, PostId: otherDraftId})
+	if err != nil || found {
+		t.Errorf("bookmarking another user's draft: found %t, error %v, want not found", found, err)
+	}
+
+	// A bookmark of another user's draft, made before bookmarks were restricted to published posts and own drafts
+	_, err = store.db.Exec("INSERT INTO post_bookmark (viewer, post_id) VALUES ($1, $2)", viewer, otherDraftId)
+	if err != nil {
+		t.Fatalf("could not insert the bookmark: %s", err)
+	}
+
+	ids := getTestBookmarkIds(t, store, viewer)
+	if !slices.Contains(ids, publishedId) || !slices.Contains(ids, ownDraftId) {
+		t.Errorf("the bookmarks %v do not include the published post %s and the viewer's draft %s", ids, publishedId, ownDraftId)
+	}
+	if slices.Contains(ids, otherDraftId) {
+		t.Errorf("the bookmarks include another user's draft %s", otherDraftId)
+	}
+}
@@ -3,6 +3,9 @@ package postgres
 import (
 	"backend/httperror"
 	"database/sql"
+	"fmt"
+	"html"
+	"strings"
 
 	"github.com/lib/pq"
 )
@@ -27,6 +30,30 @@ func NewPostgresStore(connStr string) (*PostgresStore, error) {
 	}, nil
 }
 
+// Search result snippets are made by ts_headline, which works on the raw user text and does not escape it
+// So the matches are wrapped in private use characters instead of <mark> tags, and markHighlights escapes
+// the snippet before turning them into <mark> tags. Any such characters in the text itself are removed first
+const highlightStartSel = "\ue000"
+const highlightStopSel = "\ue001"
+
+// ts_headline options for search result snippets
+// The whole title is returned, whereas only up to 3 short fragments of the body are returned
+const titleHeadlineOptions = "StartSel=\"" + highlightStartSel + "\", StopSel=\"" + highlightStopSel + "\", HighlightAll=true"
+const bodyHeadlineOptions = "StartSel=\"" + highlightStartSel + "\", StopSel=\"" + highlightStopSel + "\", MaxWords=35, MinWords=15, MaxFragments=3, FragmentDelimiter=\" ... \""
+
+var highlightReplacer = strings.NewReplacer(highlightStartSel, "<mark>", highlightStopSel, "</mark>")
+
+// Returns the SQL for a snippet of the column with the matches of the tsquery marked
+func headlineColumn(column string, tsquery string, options string) string {
+	return fmt.Sprintf("ts_headline('english', translate(%s, '%s%s', ''), %s, '%s')",
+		column, highlightStartSel, highlightStopSel, tsquery, options)
+}
+
+// Escapes a snippet made by headlineColumn so it is safe to render as HTML, and wraps its matches in <mark> tags
+func markHighlights(snippet string) string {
+	return highlightReplacer.Replace(html.EscapeString(snippet))
+}
+
 func checkPostgresErr(err error) error {
 	if pgErr, ok := err.(*pq.Error); ok {
 		switch pgErr.Code {
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"backend/httperror"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// A published post that is related to another post
+// Score is the number of shared tags plus the full-text similarity of the title and body
+type RelatedPost struct {
+	Id        string   `json:"id"`
+	Title     string   `json:"title"`
+	Author    string   `json:"author"`
+	Tags      []string `json:"tags"`
+	Likes     int      `json:"likes"`
+	Dislikes  int      `json:"dislikes"`
+	CreatedAt string   `json:"createdAt"`
+	Score     float64  `json:"score"`
+}
+
+// The number of the most frequent lexemes of the source post that are used to find similar posts
+const relatedPostLexemeLimit = 50
+
+// Gets the published posts that are most related to the published post, by tag overlap and full-text similarity
+// The full-text similarity is the rank of each post against a query of the source post's most frequent lexemes
+// Returns nil if the source post does not exist or is not published
+func (postgres *PostgresStore) GetRelatedPosts(postId string, limit int) ([]RelatedPost, error) {
+	var status string
+	query := `SELECT status FROM post WHERE id = $1`
+	err := postgres.db.QueryRow(query, postId).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	err = checkPostgresErr(err)
+	if err != nil {
+		return nil, err
+	}
+	if status != "Published" {
+		return nil, nil
+	}
+
+	// The lexemes are already normalised, so the query is built by casting instead of with to_tsquery
+	query = `
+		WITH source AS (
+			SELECT post.id,
+				   ARRAY(SELECT lower(tag) FROM post_tag WHERE post_tag.post_id = post.id) AS tags,
+				   (
+					   SELECT string_agg(quote_literal(l.lexeme), ' | ')::tsquery
+					   FROM (
+						   SELECT lexeme FROM unnest(post.textsearchable_index)
+						   ORDER BY cardinality(positions) DESC, lexeme
+						   LIMIT $2
+					   ) AS l
+				   ) AS query
+			FROM post
+			WHERE post.id = $1
+		)
+		SELECT id, title, author, tags, likes, dislikes, created_at, tag_overlap + text_similarity AS score
+		FROM (
+			SELECT post.id, post.title, post.author, p.tags, post.likes, post.dislikes, post.created_at,
+				   cardinality(ARRAY(SELECT unnest(p.tags_lowercased) INTERSECT SELECT unnest(source.tags))) AS tag_overlap,
+				   COALESCE(ts_rank_cd(post.textsearchable_index, source.query), 0) AS text_similarity
+			FROM post
+			LEFT JOIN LATERAL (
+				SELECT COALESCE(array_agg(post_tag.tag ORDER BY post_tag.tag), '{}') AS tags,
+					   COALESCE(array_agg(lower(post_tag.tag)), '{}') AS tags_lowercased
+				FROM post_tag
+				WHERE post_tag.post_id = post.id
+			) AS p ON true, source
+			WHERE post.id <> source.id AND post.status = 'Published' AND ` + notShadowBanned("post.author", "''") + `
+				AND (p.tags_lowercased && source.tags OR post.textsearchable_index @@ source.query)
+		) AS related
+		ORDER BY score DESC, created_at DESC, id
+		LIMIT $3`
+	rows, err := postgres.db.Query(query, postId, relatedPostLexemeLimit, limit)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	relatedPosts := []RelatedPost{}
+
+	for rows.Next() {
+		var relatedPost RelatedPost
+
+		err := rows.Scan(&relatedPost.Id, &relatedPost.Title, &relatedPost.Author, pq.Array(&relatedPost.Tags),
+			&relatedPost.Likes, &relatedPost.Dislikes, &relatedPost.CreatedAt, &relatedPost.Score)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		} else {
+			relatedPosts = append(relatedPosts, relatedPost)
+		}
+	}
+
+	return relatedPosts, nil
+}
@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"backend/httperror"
+	"database/sql"
+	"fmt"
+)
+
+// A result of the unified search. Type is one of "post", "comment", "tag" or "user"
+// Id is the post/comment id, the tag or the username respectively
+// Title is the title of the post (or of the post the comment belongs to), and Snippet is a snippet of its body
+// that is HTML escaped, with the matches wrapped in <mark> tags. Tags and users only have an Id
+type SearchResult struct {
+	Type      string  `json:"type"`
+	Id        string  `json:"id"`
+	Title     string  `json:"title,omitempty"`
+	Snippet   string  `json:"snippet,omitempty"`
+	Author    string  `json:"author,omitempty"`
+	PostId    string  `json:"postId,omitempty"`
+	CreatedAt string  `json:"createdAt,omitempty"`
+	Rank      float64 `json:"rank"`
+}
+
+type SearchSuggestions struct {
+	Titles []TitleSuggestion `json:"titles"`
+	Tags   []string          `json:"tags"`
+}
+
+type TitleSuggestion struct {
+	PostId string `json:"postId"`
+	Title  string `json:"title"`
+}
+
+// Gets the published post titles and the tags that best match the (possibly partial or misspelled) text
+// Both are matched by trigram word similarity, which is supported by the trigram indexes
+func (postgres *PostgresStore) GetSearchSuggestions(text string, limit int) (*SearchSuggestions, error) {
+	suggestions := SearchSuggestions{
+		Titles: []TitleSuggestion{},
+		Tags:   []string{},
+	}
+
+	query := `SELECT id, title
+			  FROM post
+			  WHERE status = 'Published' AND $1 <% title AND ` + notShadowBanned("post.author", "''") + `
+			  ORDER BY word_similarity($1, title) DESC, likes - dislikes DESC
+			  LIMIT $2`
+	rows, err := postgres.db.Query(query, text, limit)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var suggestion TitleSuggestion
+
+		err := rows.Scan(&suggestion.PostId, &suggestion.Title)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		} else {
+			suggestions.Titles = append(suggestions.Titles, suggestion)
+		}
+	}
+
+	// Tags that only differ by case are suggested once, with their most used spelling
+	// Tags that are used more often are suggested first among equally similar tags
+	query = `SELECT mode() WITHIN GROUP (ORDER BY tag)
+			 FROM post_tag
+			 WHERE lower($1) <% lower(tag)
+			 GROUP BY lower(tag)
+			 ORDER BY word_similarity(lower($1), lower(tag)) DESC, count(*) DESC
+			 LIMIT $2`
+	rows, err = postgres.db.Query(query, text, limit)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tag string
+
+		err := rows.Scan(&tag)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		} else {
+			suggestions.Tags = append(suggestions.Tags, tag)
+		}
+	}
+
+	return &suggestions, nil
+}
+
+// Searches posts, comments, tags and users with the text, and ranks all of them together by relevance
+// Posts and comments are matched with their textsearchable indexes, whereas tags and usernames are matched word by word
+// Only published posts (and the viewer's own drafts) and published comments of published posts are searched,
+// so the bodies of deleted posts and comments are never returned
+func (postgres *PostgresStore) Search(username string, text string, limit int, offset int) ([]SearchResult, error) {
+	query := fmt.Sprintf(`
+		WITH q AS (
+			SELECT websearch_to_tsquery('english', $2) AS query, websearch_to_tsquery('simple', $2) AS simple_query
+		)
+		SELECT type, id, title, snippet, author, post_id, created_at, rank
+		FROM (
+			SELECT 'post' AS type, post.id::text AS id, post.title AS title,
+				   %s AS snippet,
+				   post.author AS author, post.id::text AS post_id, post.created_at AS created_at,
+				   ts_rank_cd(post.textsearchable_index, q.query) AS rank
+			FROM post, q
+			WHERE post.textsearchable_index @@ q.query
+				AND (post.status = 'Published' OR (post.status = 'Draft' AND post.author = $1))
+				AND %s
+			UNION ALL
+			SELECT 'comment', c.id::text, post.title,
+				   %s,
+				   c.author, c.post_id::text, c.created_at,
+				   ts_rank_cd(c.textsearchable_index, q.query)
+			FROM comment AS c
+			JOIN post ON c.post_id = post.id, q
+			WHERE c.textsearchable_index @@ q.query
+				AND c.status = 'Published' AND post.status = 'Published'
+				AND %s AND %s
+			UNION ALL
+			SELECT 'tag', mode() WITHIN GROUP (ORDER BY post_tag.tag), NULL, NULL, NULL, NULL, NULL,
+				   ts_rank_cd(to_tsvector('simple', lower(post_tag.tag)), q.simple_query)
+			FROM post_tag
+			JOIN post ON post_tag.post_id = post.id, q
+			WHERE to_tsvector('simple', lower(post_tag.tag)) @@ q.simple_query
+				AND post.status = 'Published'
+			GROUP BY lower(post_tag.tag), q.simple_query
+			UNION ALL
+			SELECT 'user', username, NULL, NULL, NULL, NULL, NULL,
+				   ts_rank_cd(to_tsvector('simple', username), q.simple_query)
+			FROM user_account, q
+			WHERE to_tsvector('simple', username) @@ q.simple_query
+		) AS results
+		ORDER BY rank DESC, created_at DESC NULLS LAST, type, id
+		LIMIT $3 OFFSET $4`,
+		headlineColumn("post.body", "q.query", bodyHeadlineOptions), notShadowBanned("post.author", "$1"),
+		headlineColumn("c.body", "q.query", bodyHeadlineOptions), notShadowBanned("c.author", "$1"), notShadowBanned("post.author", "$1"))
+
+	rows, err := postgres.db.Query(query, username, text, limit, offset)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+
+	for rows.Next() {
+		var result SearchResult
+		var title, snippet, author, postId, createdAt sql.NullString
+
+		err := rows.Scan(&result.Type, &result.Id, &title, &snippet, &author, &postId, &createdAt, &result.Rank)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		} else {
+			result.Title = title.String
+			result.Snippet = markHighlights(snippet.String)
+			result.Author = author.String
+			result.PostId = postId.String
+			result.CreatedAt = createdAt.String
+			results = append(results, result)
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,40 @@
+package postgres
+
+import (
+	"database/sql"
+)
+
+// App-wide settings that can be changed at runtime by admins
+type AppSettings struct {
+	AllowFreeformTags bool `json:"allowFreeformTags"` // Whether users can add tags that are not in the tag catalogue
+}
+
+// Either a *sql.DB or a *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (postgres *PostgresStore) GetAppSettings() (*AppSettings, error) {
+	return getAppSettings(postgres.db)
+}
+
+func getAppSettings(db queryRower) (*AppSettings, error) {
+	var settings AppSettings
+
+	query := `SELECT allow_freeform_tags FROM app_settings`
+	err := db.QueryRow(query).Scan(&settings.AllowFreeformTags)
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (postgres *PostgresStore) UpdateAppSettings(settings AppSettings) error {
+	query := `UPDATE app_settings SET allow_freeform_tags = $1, updated_at = now()`
+	_, err := postgres.db.Exec(query, settings.AllowFreeformTags)
+
+	return checkPostgresErr(err)
+}
@@ -0,0 +1,373 @@
+package postgres
+
+import (
+	"backend/httperror"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Colour      string `json:"colour"`
+	Retired     bool   `json:"retired"` // Retired tags can no longer be added to posts
+	Parent      string `json:"parent"` // Posts with the tag are also listed under the parent tag. Empty if there is no parent
+	Aliases     []string `json:"aliases"` // Synonyms that are replaced by the tag when they are added to posts
+	PostCount   int    `json:"postCount"` // The number of published posts with the tag
+}
+
+type TagAlias struct {
+	Alias string
+	Tag   string
+}
+
+// Gets the tags in the catalogue, most used first
+func (postgres *PostgresStore) GetTags() ([]Tag, error) {
+	query := `SELECT tag.name, tag.description, tag.colour, tag.retired, COALESCE(tag.parent, ''),
+					 ARRAY(SELECT alias FROM tag_alias WHERE tag_alias.tag = tag.name ORDER BY alias),
+					 COUNT(post.id)
+			  FROM tag
+			  LEFT JOIN post_tag ON tag.name = post_tag.tag
+			  LEFT JOIN post ON post_tag.post_id = post.id AND post.status = 'Published'
+			  GROUP BY tag.name
+			  ORDER BY COUNT(post.id) DESC, tag.name`
+	rows, err := postgres.db.Query(query)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+
+	for rows.Next() {
+		var tag Tag
+
+		err := rows.Scan(&tag.Name, &tag.Description, &tag.Colour, &tag.Retired, &tag.Parent,
+			pq.Array(&tag.Aliases), &tag.PostCount)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		} else {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags, nil
+}
+
+// Adds the tag to the catalogue. Tag names are unique case-insensitively, and cannot be the alias of another tag
+func (postgres *PostgresStore) CreateTag(tag Tag) error {
+	query := `
+		INSERT INTO tag (name, description, colour, parent)
+		SELECT $1, $2, $3, NULLIF($4, '')
+		WHERE NOT EXISTS (SELECT 1 FROM tag_alias WHERE lower(alias) = lower($1))`
+	result, err := postgres.db.Exec(query, tag.Name, tag.Description, tag.Colour, tag.Parent)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+	if rowsAffected == 0 {
+		return TagAliasConflictError
+	}
+
+	return nil
+}
+
+// Updates the tag with the given (current) name. If the tag's name is different, the tag is renamed,
+// which is cascaded to its posts, and its follows and webhook filters are updated too
+// Returns false if the tag does not exist
+func (postgres *PostgresStore) UpdateTag(currentName string, tag Tag) (bool, error) {
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return false, httperror.NewInternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	// The new parent cannot be the tag itself or one of its descendants
+	if tag.Parent != "" {
+		var isCycle bool
+		query := `
+			WITH RECURSIVE ancestors AS (
+				SELECT name, parent FROM tag WHERE name = $1
+				UNION
+				SELECT tag.name, tag.parent FROM tag JOIN ancestors ON tag.name = ancestors.parent
+			)
+			SELECT EXISTS (SELECT 1 FROM ancestors WHERE name = $2)`
+		err = tx.QueryRow(query, tag.Parent, currentName).Scan(&isCycle)
+		err = checkPostgresErr(err)
+		if err != nil {
+			return false, err
+		}
+		if isCycle {
+			return false, TagCycleError
+		}
+	}
+
+	// The new name cannot be the alias of another tag
+	if tag.Name != currentName {
+		var isAlias bool
+		query := `SELECT EXISTS (SELECT 1 FROM tag_alias WHERE lower(alias) = lower($1))`
+		err = tx.QueryRow(query, tag.Name).Scan(&isAlias)
+		err = checkPostgresErr(err)
+		if err != nil {
+			return false, err
+		}
+		if isAlias {
+			return false, TagAliasConflictError
+		}
+	}
+
+	query := `
+		UPDATE tag SET name = $1, description = $2, colour = $3, retired = $4, parent = NULLIF($5, ''), updated_at = now()
+		WHERE name = $6`
+	result, err := tx.Exec(query, tag.Name, tag.Description, tag.Colour, tag.Retired, tag.Parent, currentName)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, httperror.NewInternalServerError(err)
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	if tag.Name != currentName {
+		err = replaceTagReferences(tx, currentName, tag.Name)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Commit the transaction if all queries are successful
+	err = tx.Commit()
+	if err != nil {
+		return false, httperror.NewInternalServerError(err)
+	}
+
+	return true, nil
+}
+
+// Moves all posts, follows, webhook filters, aliases and child tags of the source tag to the target tag,
+// then replaces the source tag with an alias of the target tag, so that it is normalised to the target tag from then on
+// Returns false if either tag does not exist
+func (postgres *PostgresStore) MergeTags(sourceName string, targetName string) (bool, error) {
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return false, httperror.NewInternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	// Lock both tags so that they cannot be renamed or merged concurrently
+	var lockedTags int
+	query := `SELECT COUNT(*) FROM (SELECT 1 FROM tag WHERE name = $1 OR name = $2 FOR UPDATE) AS t`
+	err = tx.QueryRow(query, sourceName, targetName).Scan(&lockedTags)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+	if lockedTags < 2 {
+		return false, nil
+	}
+
+	// Posts that already have the target tag only lose the source tag
+	query = `
+		UPDATE post_tag SET tag = $2
+		WHERE tag = $1 AND post_id NOT IN (SELECT post_id FROM post_tag WHERE tag = $2)`
+	_, err = tx.Exec(query, sourceName, targetName)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	query = `DELETE FROM post_tag WHERE tag = $1`
+	_, err = tx.Exec(query, sourceName)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	err = replaceTagReferences(tx, sourceName, targetName)
+	if err != nil {
+		return false, err
+	}
+
+	query = `UPDATE tag_alias SET tag = $2 WHERE tag = $1`
+	_, err = tx.Exec(query, sourceName, targetName)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	// The target tag cannot become its own parent
+	query = `UPDATE tag SET parent = CASE WHEN name = $2 THEN NULL ELSE $2 END WHERE parent = $1`
+	_, err = tx.Exec(query, sourceName, targetName)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	query = `DELETE FROM tag WHERE name = $1`
+	_, err = tx.Exec(query, sourceName)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	query = `INSERT INTO tag_alias (alias, tag) VALUES ($1, $2)`
+	_, err = tx.Exec(query, sourceName, targetName)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	// Commit the transaction if all queries are successful
+	err = tx.Commit()
+	if err != nil {
+		return false, httperror.NewInternalServerError(err)
+	}
+
+	return true, nil
+}
+
+// Replaces the old tag with the new tag in tag follows and webhook filters, which reference tags case-insensitively
+// Users that already follow the new tag keep a single follow
+func replaceTagReferences(tx *sql.Tx, oldName string, newName string) error {
+	query := `
+		DELETE FROM tag_follow
+		WHERE lower(tag) = lower($1) AND lower($1) <> lower($2)
+			AND follower IN (SELECT follower FROM tag_follow WHERE lower(tag) = lower($2))`
+	_, err := tx.Exec(query, oldName, newName)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE tag_follow SET tag = $2 WHERE lower(tag) = lower($1)`
+	_, err = tx.Exec(query, oldName, newName)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE webhook SET tags = ARRAY(
+			SELECT DISTINCT CASE WHEN lower(t) = lower($1) THEN $2 ELSE t END FROM unnest(tags) AS t
+		)
+		WHERE lower($1) = ANY(ARRAY(SELECT lower(unnest(tags))))`
+	_, err = tx.Exec(query, oldName, newName)
+
+	return checkPostgresErr(err)
+}
+
+// Returns the canonical name of the tag, which is the tag that it is an alias of if it is an alias
+// If the tag is not in the catalogue, it is added if free-form tags are allowed, or rejected otherwise
+// Retired tags are rejected unless they are in allowedRetiredTags
+func resolveTag(tx *sql.Tx, tag string, allowedRetiredTags []string) (string, error) {
+	var name string
+	var retired bool
+
+	query := `SELECT name, retired FROM tag WHERE lower(name) = lower($1)
+			  UNION ALL
+			  SELECT tag.name, tag.retired FROM tag_alias JOIN tag ON tag_alias.tag = tag.name
+			  WHERE lower(tag_alias.alias) = lower($1)
+			  LIMIT 1`
+	err := tx.QueryRow(query, tag).Scan(&name, &retired)
+
+	if err == sql.ErrNoRows {
+		settings, err := getAppSettings(tx)
+		if err != nil {
+			return "", err
+		}
+		if !settings.AllowFreeformTags {
+			return "", UnknownTagError
+		}
+
+		// Another request may have created the same tag concurrently, in which case its spelling is used
+		query = `
+			INSERT INTO tag (name) VALUES ($1)
+			ON CONFLICT DO NOTHING`
+		_, err = tx.Exec(query, tag)
+		err = checkPostgresErr(err)
+		if err != nil {
+			return "", err
+		}
+
+		query = `SELECT name, retired FROM tag WHERE lower(name) = lower($1)`
+		err = tx.QueryRow(query, tag).Scan(&name, &retired)
+	}
+
+	err = checkPostgresErr(err)
+	if err != nil {
+		return "", err
+	}
+
+	if retired {
+		for _, allowedTag := range allowedRetiredTags {
+			if strings.EqualFold(allowedTag, name) {
+				return name, nil
+			}
+		}
+		return "", RetiredTagError
+	}
+
+	return name, nil
+}
+
+// Adds the alias to the tag, or moves it to the tag if it is an alias of another tag
+// An alias cannot be the name of a tag
+func (postgres *PostgresStore) UpsertTagAlias(tagAlias TagAlias) error {
+	query := `
+		INSERT INTO tag_alias (alias, tag)
+		SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM tag WHERE lower(name) = lower($1))
+		ON CONFLICT (lower(alias)) DO UPDATE SET tag = EXCLUDED.tag`
+	result, err := postgres.db.Exec(query, tagAlias.Alias, tagAlias.Tag)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+	if rowsAffected == 0 {
+		return TagAliasConflictError
+	}
+
+	return nil
+}
+
+func (postgres *PostgresStore) DeleteTagAlias(tagAlias TagAlias) error {
+	query := `DELETE FROM tag_alias WHERE lower(alias) = lower($1) AND tag = $2`
+	_, err := postgres.db.Exec(query, tagAlias.Alias, tagAlias.Tag)
+
+	return checkPostgresErr(err)
+}
+
+// Returns an SQL expression of the lowercased tags in tagsExpression (an SQL array of lowercased tags or aliases),
+// together with all of their descendants
+func expandTags(tagsExpression string) string {
+	return fmt.Sprintf(`ARRAY(
+		WITH RECURSIVE expanded_tag AS (
+			SELECT name FROM tag WHERE lower(name) = ANY(%[1]s)
+			UNION
+			SELECT tag FROM tag_alias WHERE lower(alias) = ANY(%[1]s)
+			UNION
+			SELECT tag.name FROM tag JOIN expanded_tag ON tag.parent = expanded_tag.name
+		)
+		SELECT lower(name) FROM expanded_tag
+	)`, tagsExpression)
+}
@@ -1,6 +1,8 @@
 package postgres
 
 import (
+	"database/sql"
+	"fmt"
 	"net/http"
 	"backend/httperror"
 )
@@ -13,4 +15,66 @@ var UniqueViolationError = &httperror.Error{
 var InvalidForeignKeyError = &httperror.Error{
 	Status: http.StatusBadRequest,
 	Code: "INVALID-FOREIGN-KEY-ERROR",
-}
\ No newline at end of file
+}
+
+var UnknownTagError = &httperror.Error{
+	Status: http.StatusBadRequest,
+	Message: "Only tags from the tag catalogue can be used",
+	Code: "UNKNOWN-TAG-ERROR",
+}
+
+var RetiredTagError = &httperror.Error{
+	Status: http.StatusBadRequest,
+	Message: "Retired tags can no longer be added to posts",
+	Code: "RETIRED-TAG-ERROR",
+}
+
+var BlockedReplyError = &httperror.Error{
+	Status: http.StatusForbidden,
+	Message: "You cannot reply to this user because they have blocked you",
+	Code: "BLOCKED-REPLY-ERROR",
+}
+
+var PostNotDraftError = &httperror.Error{
+	Status: http.StatusConflict,
+	Message: "Only drafts can be published",
+	Code: "POST-NOT-DRAFT-ERROR",
+}
+
+var TagCycleError = &httperror.Error{
+	Status: http.StatusBadRequest,
+	Message: "A tag cannot be the parent of itself or of its ancestors",
+	Code: "TAG-CYCLE-ERROR",
+}
+
+var TagAliasConflictError = &httperror.Error{
+	Status: http.StatusConflict,
+	Message: "A tag and an alias cannot have the same name",
+	Code: "TAG-ALIAS-CONFLICT-ERROR",
+}
+
+// Returned when an update is based on an outdated version of a post or comment
+func NewStaleVersionError(currentVersion int) *httperror.Error {
+	return &httperror.Error{
+		Status: http.StatusPreconditionFailed,
+		Message: fmt.Sprintf("This has been changed by someone else since you last loaded it. The current version is %d", currentVersion),
+		Code: "STALE-VERSION-ERROR",
+	}
+}
+
+// Called when a versioned update did not match any row, either because the row does not exist
+// (0 and no error are returned) or because it is at another version (its current version and a StaleVersionError are returned)
+func getStaleVersion(db queryRower, table string, id string) (int, error) {
+	var version int
+	query := fmt.Sprintf(`SELECT version FROM %s WHERE id = $1`, table)
+	err := db.QueryRow(query, id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	err = checkPostgresErr(err)
+	if err != nil {
+		return 0, err
+	}
+
+	return version, NewStaleVersionError(version)
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"fmt"
+)
+
+// The filters shared by the post and comment listings. All fields are optional
+// CreatedAfter and CreatedBefore are dates in the "yyyy-mm-dd" format. CreatedAfter includes the given day,
+// whereas CreatedBefore excludes it, so that createdAfter=2024-12-01&createdBefore=2024-12-08 is one week.
+// The after: and before: search qualifiers have the same boundaries
+// MinScore is the minimum net likes (likes - dislikes)
+// HasComments filters by whether there are published comments (replies, for comments)
+// Unanswered filters by whether there are no published comments (replies, for comments) by anyone other than the author
+// UpdatedSince is an RFC 3339 timestamp, so that sync clients can fetch only what changed since their last sync
+type ListingFilter struct {
+	CreatedAfter  string
+	CreatedBefore string
+	MinScore      *int
+	HasComments   *bool
+	Unanswered    *bool
+	UpdatedSince  string
+}
+
+// The columns/expressions that a listing filter is matched against
+// replyCondition selects the comments that reply to the row, from a comment table aliased as "r"
+type listingColumns struct {
+	createdAt      string
+	updatedAt      string
+	likes          string
+	dislikes       string
+	author         string
+	replyCondition string
+}
+
+var postListingColumns = listingColumns{
+	createdAt:      "post.created_at",
+	updatedAt:      "post.updated_at",
+	likes:          "post.likes",
+	dislikes:       "post.dislikes",
+	author:         "post.author",
+	replyCondition: "r.post_id = post.id",
+}
+
+var commentListingColumns = listingColumns{
+	createdAt:      "c.created_at",
+	updatedAt:      "c.updated_at",
+	likes:          "c.likes",
+	dislikes:       "c.dislikes",
+	author:         "c.author",
+	replyCondition: "r.parent_id = c.id",
+}
+
+// Appends the conditions of the listing filter to the query
+// Returns the new query, query params and param count
+func appendListingFilterConditions(query string, conditions []any, conditionCount int,
+	filter ListingFilter, columns listingColumns) (string, []any, int) {
+
+	if filter.CreatedAfter != "" {
+		query = query + fmt.Sprintf(" AND %s >= $%v::date", columns.createdAt, conditionCount)
+		conditions = append(conditions, filter.CreatedAfter)
+		conditionCount += 1
+	}
+	if filter.CreatedBefore != "" {
+		query = query + fmt.Sprintf(" AND %s < $%v::date", columns.createdAt, conditionCount)
+		conditions = append(conditions, filter.CreatedBefore)
+		conditionCount += 1
+	}
+	if filter.MinScore != nil {
+		query = query + fmt.Sprintf(" AND %s - %s >= $%v", columns.likes, columns.dislikes, conditionCount)
+		conditions = append(conditions, *filter.MinScore)
+		conditionCount += 1
+	}
+	if filter.HasComments != nil {
+		hasComments := fmt.Sprintf("EXISTS (SELECT 1 FROM comment AS r WHERE %s AND r.status = 'Published')", columns.replyCondition)
+		if *filter.HasComments {
+			query = query + " AND " + hasComments
+		} else {
+			query = query + " AND NOT " + hasComments
+		}
+	}
+	if filter.Unanswered != nil {
+		isAnswered := fmt.Sprintf("EXISTS (SELECT 1 FROM comment AS r WHERE %s AND r.status = 'Published' AND r.author <> %s)",
+			columns.replyCondition, columns.author)
+		if *filter.Unanswered {
+			query = query + " AND NOT " + isAnswered
+		} else {
+			query = query + " AND " + isAnswered
+		}
+	}
+	if filter.UpdatedSince != "" {
+		query = query + fmt.Sprintf(" AND %s >= $%v::timestamptz", columns.updatedAt, conditionCount)
+		conditions = append(conditions, filter.UpdatedSince)
+		conditionCount += 1
+	}
+
+	return query, conditions, conditionCount
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// A search query that has been parsed into its free text and its field qualifiers. All fields are optional
+// Text is matched with websearch_to_tsquery, so it supports "quoted phrases", -excluded words and OR
+// Before and After are dates in the "yyyy-mm-dd" format. Like ListingFilter's CreatedBefore and CreatedAfter,
+// After includes the given day whereas Before excludes it, so that after:2024-12-01 before:2024-12-08 is one week
+// IsDraft restricts the results to the viewer's own drafts
+type SearchQuery struct {
+	Text    string
+	Authors []string
+	Tags    []string
+	Before  string
+	After   string
+	IsDraft bool
+}
+
+func (searchQuery SearchQuery) HasText() bool {
+	return strings.Trim(searchQuery.Text, " ") != ""
+}
+
+// The columns/expressions that the parts of a search query are matched against
+// If title is set, the text is also fuzzily matched against the title and the tags of the post with the given id,
+// so that misspelled queries still find results
+type searchColumns struct {
+	textsearchableIndex string
+	title               string
+	postId              string
+	author              string
+	status              string
+	createdAt           string
+	lowercasedTags      string
+}
+
+var postSearchColumns = searchColumns{
+	textsearchableIndex: "post.textsearchable_index",
+	title:               "post.title",
+	postId:              "post.id",
+	author:              "post.author",
+	status:              "post.status",
+	createdAt:           "post.created_at",
+	lowercasedTags:      "p.tags_lowercased",
+}
+
+// Comments are matched against the tags of the post they belong to
+var commentSearchColumns = searchColumns{
+	textsearchableIndex: "c.textsearchable_index",
+	author:              "c.author",
+	status:              "c.status",
+	createdAt:           "c.created_at",
+	lowercasedTags:      "ARRAY(SELECT lower(post_tag.tag) FROM post_tag WHERE post_tag.post_id = c.post_id)",
+}
+
+// Appends the conditions of the search query to the query. The viewer's username must be the first query param ($1)
+// Returns the new query, query params and param count
+func appendSearchQueryConditions(query string, conditions []any, conditionCount int,
+	searchQuery SearchQuery, columns searchColumns) (string, []any, int) {
+
+	if searchQuery.HasText() && columns.title != "" {
+		// "<%" is true if the text is similar enough to a part of the title/tag. It is supported by the trigram indexes
+		query = query + fmt.Sprintf(` AND (%s @@ websearch_to_tsquery('english', $%v)
+									 OR $%v <%% %s
+									 OR EXISTS (SELECT 1 FROM post_tag AS t WHERE t.post_id = %s AND lower($%v) <%% lower(t.tag)))`,
+									 columns.textsearchableIndex, conditionCount, conditionCount, columns.title, columns.postId, conditionCount)
+		conditions = append(conditions, searchQuery.Text)
+		conditionCount += 1
+	} else if searchQuery.HasText() {
+		query = query + fmt.Sprintf(" AND %s @@ websearch_to_tsquery('english', $%v)", columns.textsearchableIndex, conditionCount)
+		conditions = append(conditions, searchQuery.Text)
+		conditionCount += 1
+	}
+	if len(searchQuery.Authors) > 0 {
+		query = query + fmt.Sprintf(" AND %s = ANY($%v)", columns.author, conditionCount)
+		conditions = append(conditions, pq.Array(searchQuery.Authors))
+		conditionCount += 1
+	}
+	for _, tag := range searchQuery.Tags {
+		// Every tag in the query must be present (or one of its synonyms or descendants). Tags are matched case-insensitively
+		query = query + fmt.Sprintf(" AND %s && %s", columns.lowercasedTags, expandTags(fmt.Sprintf("ARRAY[lower($%v)]", conditionCount)))
+		conditions = append(conditions, tag)
+		conditionCount += 1
+	}
+	if searchQuery.Before != "" {
+		query = query + fmt.Sprintf(" AND %s < $%v::date", columns.createdAt, conditionCount)
+		conditions = append(conditions, searchQuery.Before)
+		conditionCount += 1
+	}
+	if searchQuery.After != "" {
+		query = query + fmt.Sprintf(" AND %s >= $%v::date", columns.createdAt, conditionCount)
+		conditions = append(conditions, searchQuery.After)
+		conditionCount += 1
+	}
+	if searchQuery.IsDraft {
+		// Users can only ever search their own drafts
+		query = query + fmt.Sprintf(" AND %s = 'Draft' AND %s = $1", columns.status, columns.author)
+	}
+
+	return query, conditions, conditionCount
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"backend/httperror"
+)
+
+// Returns the changes to the like and dislike counters when a viewer's vote changes from previousVote to currentVote
+// An empty vote means that the viewer has not voted
+func getVoteCounterChanges(previousVote string, currentVote string) (int, int) {
+	likesChange := 0
+	dislikesChange := 0
+
+	switch previousVote {
+	case "Like":
+		likesChange -= 1
+	case "Dislike":
+		dislikesChange -= 1
+	}
+
+	switch currentVote {
+	case "Like":
+		likesChange += 1
+	case "Dislike":
+		dislikesChange += 1
+	}
+
+	return likesChange, dislikesChange
+}
+
+// Recalculates the like and dislike counters of all posts and comments from their votes
+// Returns the number of posts and comments whose counters had drifted and were repaired
+func (postgres *PostgresStore) ReconcileVoteCounters() (int64, int64, error) {
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return 0, 0, httperror.NewInternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	// Block new votes until the counters are repaired. Otherwise, a vote that is cast in the meantime could be counted twice
+	// Votes lock their post/comment row before their vote row, so the tables are locked in the same order to avoid deadlocks
+	query := `LOCK TABLE post, comment IN SHARE ROW EXCLUSIVE MODE`
+	_, err = tx.Exec(query)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query = `LOCK TABLE post_vote, comment_vote IN SHARE MODE`
+	_, err = tx.Exec(query)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query = `
+		UPDATE post SET likes = v.likes, dislikes = v.dislikes
+		FROM (
+			SELECT post.id,
+					COUNT(case when post_vote.vote = 'Like' then 1 else null end) AS likes,
+					COUNT(case when post_vote.vote = 'Dislike' then 1 else null end) AS dislikes
+			FROM post
+			LEFT JOIN post_vote ON post.id = post_vote.post_id
+			GROUP BY post.id
+		) AS v
+		WHERE post.id = v.id AND (post.likes <> v.likes OR post.dislikes <> v.dislikes)`
+	postResult, err := tx.Exec(query)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query = `
+		UPDATE comment SET likes = v.likes, dislikes = v.dislikes
+		FROM (
+			SELECT comment.id,
+					COUNT(case when comment_vote.vote = 'Like' then 1 else null end) AS likes,
+					COUNT(case when comment_vote.vote = 'Dislike' then 1 else null end) AS dislikes
+			FROM comment
+			LEFT JOIN comment_vote ON comment.id = comment_vote.comment_id
+			GROUP BY comment.id
+		) AS v
+		WHERE comment.id = v.id AND (comment.likes <> v.likes OR comment.dislikes <> v.dislikes)`
+	commentResult, err := tx.Exec(query)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Commit the transaction if all queries are successful
+	err = tx.Commit()
+	if err != nil {
+		return 0, 0, httperror.NewInternalServerError(err)
+	}
+
+	postsRepaired, err := postResult.RowsAffected()
+	if err != nil {
+		return 0, 0, httperror.NewInternalServerError(err)
+	}
+	commentsRepaired, err := commentResult.RowsAffected()
+	if err != nil {
+		return 0, 0, httperror.NewInternalServerError(err)
+	}
+
+	return postsRepaired, commentsRepaired, nil
+}
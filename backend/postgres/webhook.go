@@ -0,0 +1,336 @@
+package postgres
+
+import (
+	"backend/httperror"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Webhook struct {
+	Id         string   `json:"id"`
+	TargetUrl  string   `json:"targetUrl"`
+	Secret     string   `json:"-"` // The secret is write-only so it is never revealed after creation
+	EventTypes []string `json:"eventTypes"`
+	Tags       []string `json:"tags"`
+	Active     bool     `json:"active"`
+	CreatedBy  string   `json:"createdBy"`
+	CreatedAt  string   `json:"createdAt"`
+	UpdatedAt  string   `json:"updatedAt"`
+}
+
+type WebhookDelivery struct {
+	Id            string                   `json:"id"`
+	WebhookId     string                   `json:"webhookId"`
+	EventType     string                   `json:"eventType"`
+	Payload       json.RawMessage          `json:"payload"`
+	Status        string                   `json:"status"`
+	Attempts      int                      `json:"attempts"`
+	NextAttemptAt string                   `json:"nextAttemptAt"`
+	CreatedAt     string                   `json:"createdAt"`
+	UpdatedAt     string                   `json:"updatedAt"`
+	AttemptLog    []WebhookDeliveryAttempt `json:"attemptLog"`
+}
+
+type WebhookDeliveryAttempt struct {
+	DeliveryId     string `json:"deliveryId"`
+	AttemptedAt    string `json:"attemptedAt"`
+	ResponseStatus int    `json:"responseStatus"` // 0 if no response was received
+	ErrorMessage   string `json:"errorMessage"`
+	DurationMs     int64  `json:"durationMs"`
+}
+
+// A delivery that is due, together with the details of its webhook that are needed to send it
+type DueWebhookDelivery struct {
+	Id        string
+	EventType string
+	Payload   []byte
+	Attempts  int
+	TargetUrl string
+	Secret    string
+}
+
+// The payload that is stored in the outbox and sent to the webhook's target url
+type webhookPayload struct {
+	Event      string `json:"event"`
+	OccurredAt string `json:"occurredAt"`
+	Data       any    `json:"data"`
+}
+
+// An event for the webhook outbox. Tags are the tags that webhooks filter the event by
+// Events are enqueued in the same transaction as the change they describe, so that an event is saved if and only if its change is
+type WebhookEvent struct {
+	Type string
+	Tags []string
+	Data any
+}
+
+func (postgres *PostgresStore) CreateWebhook(webhook Webhook) error {
+	query := `
+		INSERT INTO webhook (id, target_url, secret, event_types, tags, active, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := postgres.db.Exec(query, webhook.Id, webhook.TargetUrl, webhook.Secret,
+		pq.Array(webhook.EventTypes), pq.Array(webhook.Tags), webhook.Active, webhook.CreatedBy)
+
+	return checkPostgresErr(err)
+}
+
+func (postgres *PostgresStore) GetWebhooks() ([]Webhook, error) {
+	query := `SELECT id, target_url, event_types, tags, active, created_by, created_at, updated_at
+			  FROM webhook
+			  ORDER BY created_at DESC`
+	rows, err := postgres.db.Query(query)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(&webhook.Id, &webhook.TargetUrl, pq.Array(&webhook.EventTypes), pq.Array(&webhook.Tags),
+			&webhook.Active, &webhook.CreatedBy, &webhook.CreatedAt, &webhook.UpdatedAt)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		} else {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	return webhooks, nil
+}
+
+// Updates the webhook. The secret is only replaced if a new one is provided
+// Returns false if the webhook does not exist
+func (postgres *PostgresStore) UpdateWebhook(webhook Webhook) (bool, error) {
+	query := `
+		UPDATE webhook SET target_url = $1, secret = COALESCE(NULLIF($2, ''), secret), event_types = $3, tags = $4,
+			active = $5, updated_at = $6
+		WHERE id = $7`
+	result, err := postgres.db.Exec(query, webhook.TargetUrl, webhook.Secret, pq.Array(webhook.EventTypes),
+		pq.Array(webhook.Tags), webhook.Active, time.Now(), webhook.Id)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, httperror.NewInternalServerError(err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// Deletes the webhook together with its deliveries and delivery log
+// Returns false if the webhook does not exist
+func (postgres *PostgresStore) DeleteWebhook(webhookId string) (bool, error) {
+	query := `DELETE FROM webhook WHERE id = $1`
+	result, err := postgres.db.Exec(query, webhookId)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, httperror.NewInternalServerError(err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// Adds a delivery to the outbox for every active webhook that subscribes to the event type
+// and whose tag filter matches at least one of the event's tags (webhooks without a tag filter match all events)
+// Nothing is enqueued if the event is nil
+func enqueueWebhookEvent(tx *sql.Tx, event *WebhookEvent) error {
+	if event == nil {
+		return nil
+	}
+
+	payload, err := json.Marshal(webhookPayload{
+		Event:      event.Type,
+		OccurredAt: time.Now().UTC().Format(time.RFC3339),
+		Data:       event.Data,
+	})
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+
+	// Lowercase all tags to enable case-insensitive matching
+	lowercasedTags := make([]string, len(event.Tags))
+	for i := 0; i < len(event.Tags); i += 1 {
+		lowercasedTags[i] = strings.ToLower(event.Tags[i])
+	}
+
+	query := `
+		INSERT INTO webhook_delivery (webhook_id, event_type, payload)
+		SELECT id, $1, $2
+		FROM webhook
+		WHERE active
+			AND $1 = ANY(event_types)
+			AND (cardinality(tags) = 0 OR ARRAY(SELECT lower(unnest(tags))) && $3)`
+	_, err = tx.Exec(query, event.Type, payload, pq.Array(lowercasedTags))
+
+	return checkPostgresErr(err)
+}
+
+// Gets the most recent deliveries of a webhook together with their delivery log
+func (postgres *PostgresStore) GetWebhookDeliveries(webhookId string) ([]WebhookDelivery, error) {
+	query := `SELECT d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+					  d.created_at, d.updated_at,
+					  COALESCE(
+						  json_agg(json_build_object(
+							  'deliveryId', a.delivery_id,
+							  'attemptedAt', a.attempted_at,
+							  'responseStatus', COALESCE(a.response_status, 0),
+							  'errorMessage', COALESCE(a.error_message, ''),
+							  'durationMs', a.duration_ms
+						  ) ORDER BY a.attempted_at) FILTER (WHERE a.id IS NOT NULL),
+						  '[]'
+					  )
+			  FROM webhook_delivery AS d
+			  LEFT JOIN webhook_delivery_attempt AS a ON d.id = a.delivery_id
+			  WHERE d.webhook_id = $1
+			  GROUP BY d.id
+			  ORDER BY d.created_at DESC
+			  LIMIT 100`
+	rows, err := postgres.db.Query(query, webhookId)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var attemptLog []byte
+
+		err := rows.Scan(&delivery.Id, &delivery.WebhookId, &delivery.EventType, &delivery.Payload, &delivery.Status,
+			&delivery.Attempts, &delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.UpdatedAt, &attemptLog)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(attemptLog, &delivery.AttemptLog)
+		if err != nil {
+			return nil, httperror.NewInternalServerError(err)
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// Claims up to "limit" pending deliveries of active webhooks that are due
+// Claimed deliveries are leased by pushing back their next attempt by the lease, so concurrent dispatchers
+// (e.g. on other instances) skip them. If the dispatcher crashes, the delivery is retried once the lease expires
+// The lease must outlast sending all of the claimed deliveries, or they could be claimed and sent again
+// The deliveries of deactivated webhooks stay pending, and are sent if the webhook is activated again
+func (postgres *PostgresStore) ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]DueWebhookDelivery, error) {
+	query := `
+		UPDATE webhook_delivery AS d SET next_attempt_at = $1
+		FROM webhook AS w
+		WHERE d.webhook_id = w.id
+			AND d.id IN (
+				SELECT pending.id FROM webhook_delivery AS pending
+				JOIN webhook ON webhook.id = pending.webhook_id
+				WHERE pending.status = 'Pending' AND pending.next_attempt_at <= now() AND webhook.active
+				ORDER BY pending.next_attempt_at
+				LIMIT $2
+				FOR UPDATE OF pending SKIP LOCKED
+			)
+		RETURNING d.id, d.event_type, d.payload, d.attempts, w.target_url, w.secret`
+	rows, err := postgres.db.Query(query, time.Now().Add(lease), limit)
+	if err != nil {
+		return nil, httperror.NewInternalServerError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []DueWebhookDelivery{}
+
+	for rows.Next() {
+		var delivery DueWebhookDelivery
+
+		err := rows.Scan(&delivery.Id, &delivery.EventType, &delivery.Payload, &delivery.Attempts,
+			&delivery.TargetUrl, &delivery.Secret)
+
+		err = checkPostgresErr(err)
+		if err != nil {
+			return nil, err
+		} else {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	return deliveries, nil
+}
+
+// Records an attempt in the delivery log and updates the delivery's status and next attempt
+func (postgres *PostgresStore) RecordWebhookDeliveryAttempt(attempt WebhookDeliveryAttempt, status string, nextAttemptAt time.Time) error {
+	tx, err := postgres.db.Begin()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+	defer tx.Rollback()
+
+	responseStatus := sql.NullInt64{Int64: int64(attempt.ResponseStatus), Valid: attempt.ResponseStatus != 0}
+	errorMessage := sql.NullString{String: attempt.ErrorMessage, Valid: attempt.ErrorMessage != ""}
+
+	query := `
+		INSERT INTO webhook_delivery_attempt (delivery_id, response_status, error_message, duration_ms)
+		VALUES ($1, $2, $3, $4)`
+	_, err = tx.Exec(query, attempt.DeliveryId, responseStatus, errorMessage, attempt.DurationMs)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE webhook_delivery SET status = $1, attempts = attempts + 1, next_attempt_at = $2, updated_at = $3
+		WHERE id = $4`
+	_, err = tx.Exec(query, status, nextAttemptAt, time.Now(), attempt.DeliveryId)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return err
+	}
+
+	// Commit the transaction if all queries are successful
+	err = tx.Commit()
+	if err != nil {
+		return httperror.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// Queues the delivery to be sent again immediately, with a fresh set of retries
+// Returns false if the delivery does not exist
+func (postgres *PostgresStore) RedeliverWebhookDelivery(webhookId string, deliveryId string) (bool, error) {
+	query := `
+		UPDATE webhook_delivery SET status = 'Pending', attempts = 0, next_attempt_at = $1, updated_at = $1
+		WHERE id = $2 AND webhook_id = $3`
+	result, err := postgres.db.Exec(query, time.Now(), deliveryId, webhookId)
+	err = checkPostgresErr(err)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, httperror.NewInternalServerError(err)
+	}
+
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"backend/postgres"
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+// Where security and moderation events are recorded. Implemented by postgres.PostgresStore
+type auditLog interface {
+	CreateAuditEvent(event postgres.AuditEvent) error
+}
+
+const defaultAuditEventPageSize = 50
+
+// Records a security or moderation event in the audit log, along with the request's IP and id
+// The event has already happened by then, so a failure does not fail the request. It is logged with the event's details instead
+func (router *Router) recordAuditEvent(r *http.Request, actor string, action string, targetType string, targetId string, metadata map[string]any) {
+	requestLogger := getRequestLogger(r)
+
+	encodedMetadata, err := json.Marshal(metadata)
+	if err != nil || metadata == nil {
+		encodedMetadata = []byte("{}")
+	}
+
+	// RemoteAddr includes the port, which is of no use for auditing
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	event := postgres.AuditEvent{
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Ip:         ip,
+		RequestId:  getRequestId(r),
+		Metadata:   encodedMetadata,
+	}
+	err = router.auditLog.CreateAuditEvent(event)
+	if err != nil {
+		requestLogger.Error("AUDIT-EVENT-FAILED", "action", action, "actor", actor, "targetType", targetType,
+			"targetId", targetId, "metadata", string(encodedMetadata), "errorMessage", err.Error())
+	}
+}
+
+// Lists the audit events, filtered by actor, action, target and creation date
+func (router *Router) handleGetAuditEvents(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Actor         string `validate:"omitempty,max=20" name:"actor"`
+		Action        string `validate:"omitempty,max=50" name:"action"`
+		TargetType    string `validate:"omitempty,max=20" name:"target type"`
+		TargetId      string `validate:"omitempty,max=300" name:"target id"`
+		CreatedAfter  string `validate:"omitempty,isIsoDate" name:"created after date"`
+		CreatedBefore string `validate:"omitempty,isIsoDate" name:"created before date"`
+		Page          int    `validate:"min=1" name:"page"`
+		PageSize      int    `validate:"min=1,max=100" name:"page size"`
+	}
+
+	page, pageSize, err := getPaginationParams(r, defaultAuditEventPageSize)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	input := requestInput{
+		Actor:         r.URL.Query().Get("actor"),
+		Action:        r.URL.Query().Get("action"),
+		TargetType:    r.URL.Query().Get("targetType"),
+		TargetId:      r.URL.Query().Get("targetId"),
+		CreatedAfter:  r.URL.Query().Get("createdAfter"),
+		CreatedBefore: r.URL.Query().Get("createdBefore"),
+		Page:          page,
+		PageSize:      pageSize,
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	limit, offset := getLimitAndOffset(input.Page, input.PageSize)
+	filter := postgres.AuditEventFilter{
+		Actor:         input.Actor,
+		Action:        input.Action,
+		TargetType:    input.TargetType,
+		TargetId:      input.TargetId,
+		CreatedAfter:  input.CreatedAfter,
+		CreatedBefore: input.CreatedBefore,
+		Limit:         limit,
+		Offset:        offset,
+	}
+	events, err := router.postgresStore.GetAuditEvents(filter)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("AUDIT-EVENTS-FETCHED", "actor", input.Actor, "action", input.Action, "targetType", input.TargetType,
+		"targetId", input.TargetId, "page", input.Page, "pageSize", input.PageSize)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(events)
+}
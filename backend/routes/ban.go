@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"backend/httperror"
+	"backend/postgres"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func (router *Router) handleGetUserBans(w http.ResponseWriter, r *http.Request) {
+	bans, err := router.postgresStore.GetUserBans()
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("USER-BANS-FETCHED")
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(bans)
+}
+
+func (router *Router) handleUpsertUserBan(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Username  string `validate:"required,notBlank" name:"username"`
+		Type      string `validate:"required,oneof=Ban ShadowBan" name:"type"`
+		Reason    string `validate:"required,notBlank,max=500" name:"reason"`
+		ExpiresAt string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00" name:"expiry"` // Leave out for a permanent ban
+	}
+
+	var input requestInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(ErrInvalidJSON, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	input.Username = vars["username"]
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	if input.ExpiresAt != "" {
+		expiresAt, _ := time.Parse(time.RFC3339, input.ExpiresAt)
+		if !expiresAt.After(time.Now()) {
+			sendToErrorHandlingMiddleware(NewInputValidationError(map[string]string{"expiresAt": "The expiry must be in the future"}), r)
+			return
+		}
+	}
+
+	user := getAuthenticatedUser(r)
+	if input.Username == user.Username {
+		sendToErrorHandlingMiddleware(ErrSelfBan, r)
+		return
+	}
+
+	// Moderators cannot silence each other (or admins)
+	roles, err := router.authEnforcer.GetImplicitRolesForUser(input.Username)
+	if err != nil {
+		sendToErrorHandlingMiddleware(httperror.NewInternalServerError(err), r)
+		return
+	}
+	if slices.Contains(roles, moderatorRole) {
+		sendToErrorHandlingMiddleware(ErrModeratorBan, r)
+		return
+	}
+
+	// Make DB query
+	ban := postgres.UserBan{
+		Username: input.Username,
+		Type:     input.Type,
+		Reason:   input.Reason,
+		IssuedBy: user.Username,
+	}
+	if input.ExpiresAt != "" {
+		ban.ExpiresAt = &input.ExpiresAt
+	}
+	err = router.postgresStore.UpsertUserBan(ban)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("USER-BAN-UPSERTED", "username", ban.Username, "type", ban.Type, "reason", ban.Reason, "expiresAt", input.ExpiresAt, "issuedBy", ban.IssuedBy)
+	router.recordAuditEvent(r, ban.IssuedBy, "USER-BAN-UPSERTED", "user", ban.Username,
+		map[string]any{"type": ban.Type, "reason": ban.Reason, "expiresAt": ban.ExpiresAt})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (router *Router) handleDeleteUserBan(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Username string `validate:"required,notBlank" name:"username"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Username: vars["username"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	found, err := router.postgresStore.DeleteUserBan(input.Username)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	if !found {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("USER-BAN-DELETED", "username", input.Username)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "USER-BAN-DELETED", "user", input.Username, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Checks whether the user is shadow-banned, so that their new content is not announced to others
+// (e.g. with live updates or webhooks). Errors are logged and treated as not shadow-banned
+func (router *Router) isShadowBanned(r *http.Request, username string) bool {
+	ban, err := router.postgresStore.GetActiveUserBan(username)
+	if err != nil {
+		requestLogger := getRequestLogger(r)
+		requestLogger.Error("USER-BAN-CHECK-FAILED", "username", username, "errorMessage", err.Error())
+		return false
+	}
+
+	return ban != nil && ban.Type == "ShadowBan"
+}
@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"backend/postgres"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (router *Router) handleUpsertUserBlock(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Username string `validate:"required,notBlank" name:"username"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Username: vars["username"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	user := getAuthenticatedUser(r)
+	if input.Username == user.Username {
+		sendToErrorHandlingMiddleware(ErrSelfBlock, r)
+		return
+	}
+
+	// Make DB query
+	userBlock := postgres.UserBlock{
+		Blocker: user.Username,
+		Blocked: input.Username,
+	}
+	err = router.postgresStore.UpsertUserBlock(userBlock)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Debug("USER-BLOCK-UPSERTED", "blocker", userBlock.Blocker, "blocked", userBlock.Blocked) // Blocks are private, so they are not audited
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (router *Router) handleDeleteUserBlock(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Username string `validate:"required,notBlank" name:"username"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Username: vars["username"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	user := getAuthenticatedUser(r)
+	userBlock := postgres.UserBlock{
+		Blocker: user.Username,
+		Blocked: input.Username,
+	}
+	err = router.postgresStore.DeleteUserBlock(userBlock)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Debug("USER-BLOCK-DELETED", "blocker", userBlock.Blocker, "blocked", userBlock.Blocked) // Blocks are private, so they are not audited
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (router *Router) handleGetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	user := getAuthenticatedUser(r)
+	blockedUsers, err := router.postgresStore.GetBlockedUsers(user.Username)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("BLOCKED-USERS-FETCHED", "username", user.Username)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(blockedUsers)
+}
@@ -14,10 +14,12 @@ import (
 type getCommentsRequestInput struct {
 	PostId string `validate:"omitempty,notBlank,uuid4" name:"post id"`
 	Query string `validate:"omitempty,notBlank" name:"query"`
+	Highlight string `validate:"omitempty,boolean" name:"highlight"` // Only used if there is a query
 	Author string `validate:"omitempty,notBlank" name:"author"`
 	LikedBy string `validate:"omitempty,notBlank" name:"liked by"`
 	Statuses []string `validate:"omitempty,dive,oneof=Draft Published Deleted" name:"status"`
 	SortBy string `validate:"omitempty,oneof=Newest Oldest Popular Relevance" name:"sort by"`
+	ListingFilter listingFilterInput
 }
 
 func (router *Router) getComments(w http.ResponseWriter, r *http.Request, input getCommentsRequestInput) {
@@ -29,29 +31,49 @@ func (router *Router) getComments(w http.ResponseWriter, r *http.Request, input
 		return
 	}
 
-	// Make DB query
 	user := getAuthenticatedUser(r)
-	comments, err := router.postgresStore.GetComments(user.Username, input.PostId, input.Author, input.Statuses, input.Query, input.LikedBy, input.SortBy)
+	searchQuery, statuses, err := getSearchQuery(input.Query, user, input.Statuses, false)
 	if err != nil {
 		sendToErrorHandlingMiddleware(err, r)
 		return
 	}
 
-	requestLogger := getRequestLogger(r)
-	requestLogger.Info("COMMENTS-FETCHED", "commentId", input.PostId, "author",  input.Author, "status", input.Statuses, "query", input.Query, "likedBy", input.LikedBy, "sortBy", input.SortBy)
+	// Make DB query
+	filter := postgres.CommentFilter{
+		PostId: input.PostId,
+		Author: input.Author,
+		Statuses: statuses,
+		SearchQuery: searchQuery,
+		LikedBy: input.LikedBy,
+		SortBy: input.SortBy,
+		Highlight: input.Highlight == "true",
+		ListingFilter: input.ListingFilter.toListingFilter(),
+	}
+	comments, err := router.postgresStore.GetComments(user.Username, filter)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("content-type", "application/json")
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("COMMENTS-FETCHED", "commentId", input.PostId, "author",  input.Author, "status", input.Statuses, "query", input.Query, "highlight", input.Highlight, "likedBy", input.LikedBy, "sortBy", input.SortBy)
 
-	json.NewEncoder(w).Encode(comments)
+	writeJSONWithConditionalGet(w, r, comments)
 }
 
 func (router *Router) handleGetCommentsByPostId(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	listingFilter, err := getListingFilterParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
 	input := getCommentsRequestInput{
 		PostId: vars["postId"],
 		SortBy: "Oldest",
 		Statuses: []string{"Published", "Deleted"},
+		ListingFilter: listingFilter,
 	}
 
 	router.getComments(w, r, input)
@@ -60,11 +82,19 @@ func (router *Router) handleGetCommentsByPostId(w http.ResponseWriter, r *http.R
 func (router *Router) handleGetMyComments(w http.ResponseWriter, r *http.Request) {
 	user := getAuthenticatedUser(r)
 
+	listingFilter, err := getListingFilterParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
 	input := getCommentsRequestInput{
 		Query: r.URL.Query().Get("query"),
+		Highlight: r.URL.Query().Get("highlight"),
 		SortBy: r.URL.Query().Get("sortBy"),
 		Author: user.Username,
 		Statuses: []string{"Published", "Deleted"},
+		ListingFilter: listingFilter,
 	}
 
 	router.getComments(w, r, input)
@@ -73,11 +103,19 @@ func (router *Router) handleGetMyComments(w http.ResponseWriter, r *http.Request
 func (router *Router) handleGetLikedComments(w http.ResponseWriter, r *http.Request) {
 	user := getAuthenticatedUser(r)
 
+	listingFilter, err := getListingFilterParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
 	input := getCommentsRequestInput{
 		Query: r.URL.Query().Get("query"),
+		Highlight: r.URL.Query().Get("highlight"),
 		SortBy: r.URL.Query().Get("sortBy"),
 		LikedBy: user.Username,
 		Statuses: []string{"Published", "Deleted"},
+		ListingFilter: listingFilter,
 	}
 
 	router.getComments(w, r, input)
@@ -137,30 +175,31 @@ func (router *Router) handleCreateComment(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// The comments of shadow-banned users are not announced
 	// If there is no error from getCommentParams, comment is guaranteed to be non-nil,
 	// so dereferencing can occur safely
-	err = router.postgresStore.CreateComment(*comment) 
+	shadowBanned := router.isShadowBanned(r, comment.Author)
+	var webhookEvent *postgres.WebhookEvent
+	if !shadowBanned {
+		webhookEvent, err = router.newCommentWebhookEvent(*comment)
+		if err != nil {
+			sendToErrorHandlingMiddleware(err, r)
+			return
+		}
+	}
+	err = router.postgresStore.CreateComment(*comment, webhookEvent) 
 	if err != nil {
 		sendToErrorHandlingMiddleware(err, r)
 		return
 	}
 
-	// Add authorisation rules that allow the author to update/delete the comment
-	var authenticatedPolicies = [][]string{
-		{comment.Author, fmt.Sprintf("/api/{version}/comments/%s", comment.Id), "PUT"}, // Auth to update the comment
-		{comment.Author, fmt.Sprintf("/api/{version}/comments/%s", comment.Id), "DELETE"}, // Auth to delete the comment
-	}
-
-	err = addAuthPolicies(authenticatedPolicies, router.authEnforcer)
-	if (err != nil) {
-		sendToErrorHandlingMiddleware(err, r)
-		return		
-	}
-
-
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("COMMENT-CREATED", "commentId", comment.Id)
 
+	if !shadowBanned {
+		router.publishCommentCreated(r, *comment)
+	}
+
 	w.WriteHeader(http.StatusCreated)
 }
 
@@ -171,13 +210,28 @@ func (router *Router) handleUpdateComment(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// The comment is only updated if it has not been changed since the version that the user edited
+	comment.Version, err = getIfMatchVersion(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
 	// If there is no error from getCommentParams, comment is guaranteed to be non-nil,
 	// so dereferencing can occur safely
-	err = router.postgresStore.UpdateComment(*comment)
+	version, err := router.postgresStore.UpdateComment(*comment)
+	if version > 0 {
+		// On a stale version error, this is the current version
+		w.Header().Set("ETag", versionETag(version))
+	}
 	if err != nil {
 		sendToErrorHandlingMiddleware(err, r)
 		return
 	}
+	if version == 0 {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
 
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("COMMENT-UPDATED", "commentId", comment.Id)
@@ -212,6 +266,7 @@ func (router *Router) handleDeleteComment(w http.ResponseWriter, r *http.Request
 
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("COMMENT-SOFT-DELETED", "commentId", input.Id)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "COMMENT-SOFT-DELETED", "comment", input.Id, nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -255,6 +310,8 @@ func (router *Router) handleUpsertCommentVote(w http.ResponseWriter, r *http.Req
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("COMMENT-VOTE-UPSERTED", "commentId", commentVote.CommentId, "viewer", commentVote.Viewer)
 
+	router.publishCommentVoteCount(r, commentVote.CommentId)
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -291,5 +348,7 @@ func (router *Router) handleDeleteCommentVote(w http.ResponseWriter, r *http.Req
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("POST-VOTE-DELETED", "commentId", commentVote.CommentId, "viewer", commentVote.Viewer)
 
+	router.publishCommentVoteCount(r, commentVote.CommentId)
+
 	w.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,197 @@
+package routes
+
+import (
+	"backend/events"
+	"backend/postgres"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Keep-alive comments are sent at this interval so that proxies do not close idle streams
+const eventStreamKeepAliveInterval = 25 * time.Second
+
+// Streams new comments and vote counts of the watched post, as well as the user's notifications, as Server-Sent Events
+func (router *Router) handleGetEvents(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		PostId string `validate:"omitempty,notBlank,uuid4" name:"post id"`
+	}
+
+	input := requestInput{
+		PostId: r.URL.Query().Get("postId"),
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	user := getAuthenticatedUser(r)
+	if input.PostId == "" && user.Username == "" {
+		// Non-logged in users have no notifications, so they must watch a post
+		sendToErrorHandlingMiddleware(NewInputValidationError(map[string]string{"postId": "You must provide a post id"}), r)
+		return
+	}
+
+	if input.PostId != "" {
+		// Only allow users to watch posts they are allowed to view
+		post, err := router.postgresStore.GetPostById(user.Username, input.PostId)
+		if err != nil {
+			sendToErrorHandlingMiddleware(err, r)
+			return
+		}
+		if post == nil || (post.Status == "Draft" && post.Author != user.Username) {
+			sendToErrorHandlingMiddleware(Err404NotFound, r)
+			return
+		}
+	}
+
+	responseController := http.NewResponseController(w)
+
+	subscription := router.eventHub.Subscribe(input.PostId, user.Username)
+	defer router.eventHub.Unsubscribe(subscription)
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("EVENT-STREAM-OPENED", "postId", input.PostId)
+
+	w.Header().Add("content-type", "text/event-stream")
+	w.Header().Add("cache-control", "no-cache")
+	w.Header().Add("x-accel-buffering", "no") // Stops reverse proxies from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	if err := responseController.Flush(); err != nil {
+		requestLogger.Warn("EVENT-STREAM-FLUSH-FAILED", "errorMessage", err.Error())
+		return
+	}
+
+	keepAlive := time.NewTicker(eventStreamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			requestLogger.Info("EVENT-STREAM-CLOSED", "postId", input.PostId)
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event := <-subscription.Events:
+			payload, err := json.Marshal(event)
+			if err != nil {
+				requestLogger.Warn("EVENT-ENCODING-FAILED", "type", event.Type, "errorMessage", err.Error())
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, payload)
+		}
+
+		if err := responseController.Flush(); err != nil {
+			// The client has disconnected
+			return
+		}
+	}
+}
+
+// Publishes an event to the event hub
+// Failing to publish an event does not fail the request because the change has already been saved
+func (router *Router) publishEvent(r *http.Request, eventType string, postId string, recipient string, data any) {
+	requestLogger := getRequestLogger(r)
+
+	event, err := events.NewEvent(eventType, postId, recipient, data)
+	if err == nil {
+		err = router.eventHub.Publish(event)
+	}
+	if err != nil {
+		requestLogger.Warn("EVENT-PUBLISH-FAILED", "type", eventType, "errorMessage", err.Error())
+	}
+}
+
+// Publishes a new comment to the watchers of its post and notifies the authors of the post and parent comment
+func (router *Router) publishCommentCreated(r *http.Request, comment postgres.Comment) {
+	type commentCreatedData struct {
+		CommentId string `json:"commentId"`
+		PostId    string `json:"postId"`
+		Author    string `json:"author"`
+		ParentId  string `json:"parentId,omitempty"`
+	}
+
+	type notificationData struct {
+		Kind      string `json:"kind"`
+		CommentId string `json:"commentId"`
+		PostId    string `json:"postId"`
+		Author    string `json:"author"`
+	}
+
+	data := commentCreatedData{
+		CommentId: comment.Id,
+		PostId:    comment.PostId,
+		Author:    comment.Author,
+	}
+	if comment.ParentComment != nil {
+		data.ParentId = comment.ParentComment.Id
+	}
+	router.publishEvent(r, "comment.created", comment.PostId, "", data)
+
+	// Notify the parent comment's author of the reply
+	notified := map[string]bool{comment.Author: true} // Users are not notified of their own comments
+	if comment.ParentComment != nil && !notified[comment.ParentComment.Author] {
+		router.publishEvent(r, "notification", "", comment.ParentComment.Author, notificationData{
+			Kind:      "comment.reply",
+			CommentId: comment.Id,
+			PostId:    comment.PostId,
+			Author:    comment.Author,
+		})
+		notified[comment.ParentComment.Author] = true
+	}
+
+	// Notify the post's author of the comment, unless they have blocked the comment's author
+	post, err := router.postgresStore.GetPostById("", comment.PostId)
+	if err != nil || post == nil {
+		return
+	}
+	if notified[post.Author] {
+		return
+	}
+	blocked, err := router.postgresStore.IsUserBlocked(postgres.UserBlock{Blocker: post.Author, Blocked: comment.Author})
+	if err != nil {
+		getRequestLogger(r).Warn("EVENT-PUBLISH-FAILED", "type", "notification", "errorMessage", err.Error())
+		return
+	}
+	if !blocked {
+		router.publishEvent(r, "notification", "", post.Author, notificationData{
+			Kind:      "post.comment",
+			CommentId: comment.Id,
+			PostId:    comment.PostId,
+			Author:    comment.Author,
+		})
+	}
+}
+
+// Publishes the post's new vote count to the watchers of the post
+func (router *Router) publishPostVoteCount(r *http.Request, postId string) {
+	voteCount, err := router.postgresStore.GetPostVoteCount(postId)
+	if err != nil {
+		getRequestLogger(r).Warn("EVENT-PUBLISH-FAILED", "type", "post.vote", "errorMessage", err.Error())
+		return
+	}
+	if voteCount == nil {
+		return
+	}
+
+	router.publishEvent(r, "post.vote", postId, "", voteCount)
+}
+
+// Publishes the comment's new vote count to the watchers of the comment's post
+func (router *Router) publishCommentVoteCount(r *http.Request, commentId string) {
+	voteCount, err := router.postgresStore.GetCommentVoteCount(commentId)
+	if err != nil {
+		getRequestLogger(r).Warn("EVENT-PUBLISH-FAILED", "type", "comment.vote", "errorMessage", err.Error())
+		return
+	}
+	if voteCount == nil {
+		return
+	}
+
+	router.publishEvent(r, "comment.vote", voteCount.PostId, "", voteCount)
+}
@@ -0,0 +1,285 @@
+package routes
+
+import (
+	"backend/postgres"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// The number of newest posts in every feed
+const feedSize = 20
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	Id         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Author     atomAuthor     `xml:"author"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Categories  []string `xml:"category"`
+	Guid        rssGuid  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func (router *Router) handleGetPostsFeed(w http.ResponseWriter, r *http.Request) {
+	router.writePostsFeed(w, r, "Latest posts", postgres.PostFilter{})
+}
+
+func (router *Router) handleGetTagFeed(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Tag string `validate:"required,notBlank,max=30" name:"tag"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Tag: vars["tag"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	router.writePostsFeed(w, r, fmt.Sprintf("Posts tagged %s", input.Tag), postgres.PostFilter{Tags: []string{input.Tag}})
+}
+
+// Every post is attributed to its author's username, so every author has a feed
+func (router *Router) handleGetAuthorFeed(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Username string `validate:"required,notBlank" name:"username"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Username: vars["username"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	router.writePostsFeed(w, r, fmt.Sprintf("Posts by %s", input.Username), postgres.PostFilter{Author: input.Username})
+}
+
+// Writes the newest published posts that match the filter as an Atom or RSS 2.0 feed, depending on the "format" path variable
+// The feed is not viewer-specific, so the posts are fetched anonymously
+func (router *Router) writePostsFeed(w http.ResponseWriter, r *http.Request, title string, filter postgres.PostFilter) {
+	filter.Statuses = []string{"Published"}
+	filter.SortBy = "Newest"
+	filter.Limit = feedSize
+
+	// Make DB query
+	posts, err := router.postgresStore.GetPosts("", filter)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// The feed was last modified when its most recently updated post was
+	var lastModified time.Time
+	for _, post := range posts {
+		updatedAt, err := time.Parse(time.RFC3339Nano, post.UpdatedAt)
+		if err == nil && updatedAt.After(lastModified) {
+			lastModified = updatedAt
+		}
+	}
+
+	siteUrl := fmt.Sprintf("%v://%v", os.Getenv("FRONTEND_PROTOCOL"), os.Getenv("FRONTEND_DOMAIN"))
+	feedUrl := siteUrl + r.URL.Path
+	format := mux.Vars(r)["format"]
+
+	var feed any
+	var contentType string
+	if format == "rss" {
+		feed = newRssFeed(title, siteUrl, posts, lastModified)
+		contentType = "application/rss+xml; charset=utf-8"
+	} else {
+		feed = newAtomFeed(title, siteUrl, feedUrl, posts, lastModified)
+		contentType = "application/atom+xml; charset=utf-8"
+	}
+
+	body, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	body = append([]byte(xml.Header), body...)
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("POSTS-FEED-FETCHED", "format", format, "tags", filter.Tags, "author", filter.Author)
+
+	// Feeds are the same for every viewer, so shared caches may serve them for a short time
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeWithConditionalGet(w, r, contentType, body, lastModified)
+}
+
+func newAtomFeed(title string, siteUrl string, feedUrl string, posts []postgres.Post, lastModified time.Time) atomFeed {
+	if lastModified.IsZero() {
+		lastModified = time.Unix(0, 0)
+	}
+
+	feed := atomFeed{
+		Title:   title,
+		Id:      feedUrl,
+		Updated: lastModified.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feedUrl, Rel: "self", Type: "application/atom+xml"},
+			{Href: siteUrl, Rel: "alternate", Type: "text/html"},
+		},
+		Entries: []atomEntry{},
+	}
+
+	for _, post := range posts {
+		entry := atomEntry{
+			Title:     post.Title,
+			Id:        "urn:uuid:" + post.Id,
+			Updated:   formatFeedTime(post.UpdatedAt, time.RFC3339),
+			Published: formatFeedTime(post.CreatedAt, time.RFC3339),
+			Author:    atomAuthor{Name: post.Author},
+			Links:     []atomLink{{Href: getPostUrl(siteUrl, post.Id), Rel: "alternate", Type: "text/html"}},
+			Content:   atomContent{Type: "html", Body: renderFeedContent(post.Body)},
+		}
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+func newRssFeed(title string, siteUrl string, posts []postgres.Post, lastModified time.Time) rssFeed {
+	channel := rssChannel{
+		Title:       title,
+		Link:        siteUrl,
+		Description: title,
+		Items:       []rssItem{},
+	}
+	if !lastModified.IsZero() {
+		channel.LastBuildDate = lastModified.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, post := range posts {
+		channel.Items = append(channel.Items, rssItem{
+			Title:       post.Title,
+			Link:        getPostUrl(siteUrl, post.Id),
+			Description: renderFeedContent(post.Body),
+			Categories:  post.Tags,
+			Guid:        rssGuid{IsPermaLink: false, Value: post.Id},
+			PubDate:     formatFeedTime(post.CreatedAt, time.RFC1123Z),
+		})
+	}
+
+	return rssFeed{
+		Version: "2.0",
+		Channel: channel,
+	}
+}
+
+func getPostUrl(siteUrl string, postId string) string {
+	return fmt.Sprintf("%s/posts/%s", siteUrl, postId)
+}
+
+// Converts a timestamp from the DB to the given layout
+func formatFeedTime(timestamp string, layout string) string {
+	parsedTimestamp, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return timestamp
+	}
+
+	return parsedTimestamp.UTC().Format(layout)
+}
+
+// Renders the post body as HTML for feed readers. The body is escaped entirely, so no markup from users
+// reaches feed readers. Blank lines separate paragraphs and line breaks are kept
+func renderFeedContent(body string) string {
+	var content strings.Builder
+
+	for _, paragraph := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+
+		lines := strings.Split(paragraph, "\n")
+		for i := range lines {
+			lines[i] = html.EscapeString(lines[i])
+		}
+
+		content.WriteString("<p>")
+		content.WriteString(strings.Join(lines, "<br>"))
+		content.WriteString("</p>")
+	}
+
+	return content.String()
+}
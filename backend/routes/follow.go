@@ -0,0 +1,244 @@
+package routes
+
+import (
+	"backend/postgres"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (router *Router) handleUpsertUserFollow(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Username string `validate:"required,notBlank" name:"username"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Username: vars["username"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	user := getAuthenticatedUser(r)
+	if input.Username == user.Username {
+		sendToErrorHandlingMiddleware(ErrSelfFollow, r)
+		return
+	}
+
+	// Make DB query
+	userFollow := postgres.UserFollow{
+		Follower: user.Username,
+		Followee: input.Username,
+	}
+	err = router.postgresStore.UpsertUserFollow(userFollow)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("USER-FOLLOW-UPSERTED", "follower", userFollow.Follower, "followee", userFollow.Followee)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (router *Router) handleDeleteUserFollow(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Username string `validate:"required,notBlank" name:"username"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Username: vars["username"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	user := getAuthenticatedUser(r)
+	userFollow := postgres.UserFollow{
+		Follower: user.Username,
+		Followee: input.Username,
+	}
+	err = router.postgresStore.DeleteUserFollow(userFollow)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("USER-FOLLOW-DELETED", "follower", userFollow.Follower, "followee", userFollow.Followee)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (router *Router) handleUpsertTagFollow(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Tag string `validate:"required,notBlank,max=30" name:"tag"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Tag: vars["tag"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	user := getAuthenticatedUser(r)
+	tagFollow := postgres.TagFollow{
+		Follower: user.Username,
+		Tag:      input.Tag,
+	}
+	err = router.postgresStore.UpsertTagFollow(tagFollow)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("TAG-FOLLOW-UPSERTED", "follower", tagFollow.Follower, "tag", tagFollow.Tag)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (router *Router) handleDeleteTagFollow(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Tag string `validate:"required,notBlank,max=30" name:"tag"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Tag: vars["tag"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	user := getAuthenticatedUser(r)
+	tagFollow := postgres.TagFollow{
+		Follower: user.Username,
+		Tag:      input.Tag,
+	}
+	err = router.postgresStore.DeleteTagFollow(tagFollow)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("TAG-FOLLOW-DELETED", "follower", tagFollow.Follower, "tag", tagFollow.Tag)
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Gets the users and tags that the user follows
+func (router *Router) handleGetFollowing(w http.ResponseWriter, r *http.Request) {
+	user := getAuthenticatedUser(r)
+	following, err := router.postgresStore.GetFollowing(user.Username)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("FOLLOWING-FETCHED", "username", user.Username)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(following)
+}
+
+func (router *Router) handleGetUserFollowerCount(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Username string `validate:"required,notBlank" name:"username"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Username: vars["username"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	followerCount, err := router.postgresStore.GetUserFollowerCount(input.Username)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("USER-FOLLOWER-COUNT-FETCHED", "followee", input.Username)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(followerCount)
+}
+
+func (router *Router) handleGetTagFollowerCount(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Tag string `validate:"required,notBlank,max=30" name:"tag"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Tag: vars["tag"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	followerCount, err := router.postgresStore.GetTagFollowerCount(input.Tag)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("TAG-FOLLOWER-COUNT-FETCHED", "tag", input.Tag)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(followerCount)
+}
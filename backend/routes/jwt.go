@@ -53,6 +53,8 @@ func (router *Router) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if !passwordMatch || noUser {
+		// Failed logins are audited so that password guessing can be spotted
+		router.recordAuditEvent(r, "", "LOGIN-FAILED", "user", reqBody.Username, nil)
 		sendToErrorHandlingMiddleware(ErrUserUnauthenticated, r)
 		return
 	}
@@ -64,8 +66,9 @@ func (router *Router) handleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	reqLogger := getRequestLogger(r)
-	reqLogger.Info("JWT-CREATED", "jwt", authCookie.Value, "username", user.Username)
+	reqLogger.Info("JWT-CREATED", "username", user.Username)
 	reqLogger.Info("USER-AUTHENTICATED", "username", user.Username)
+	router.recordAuditEvent(r, user.Username, "JWT-CREATED", "user", user.Username, nil)
 	
 	http.SetCookie(w, authCookie) // Cookie must be set before header is written otherwise cookie will not be set
 	w.WriteHeader(http.StatusCreated)	
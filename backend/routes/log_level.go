@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+func (router *Router) handleGetLogLevel(w http.ResponseWriter, r *http.Request) {
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("LOG-LEVEL-FETCHED")
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(logLevel{Level: logLevelName(router.rootLogger.Level())})
+}
+
+// Changes the minimum level of the logs without restarting. Only this instance is affected,
+// and the level goes back to LOG_LEVEL when the instance restarts
+func (router *Router) handleUpdateLogLevel(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Level string `validate:"required,notBlank" name:"level"`
+	}
+
+	var input requestInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(ErrInvalidJSON, r)
+		return
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	level, err := ParseLogLevel(input.Level)
+	if err != nil {
+		sendToErrorHandlingMiddleware(NewInputValidationError(map[string]string{"level": "The level must be debug, info, warn, error or fatal"}), r)
+		return
+	}
+
+	previousLevel := router.rootLogger.Level()
+	router.rootLogger.SetLevel(level)
+
+	// Logged at the warn level so that the change is not hidden by the new level, unless it is above warn
+	requestLogger := getRequestLogger(r)
+	requestLogger.Warn("LOG-LEVEL-UPDATED", "previousLevel", logLevelName(previousLevel), "level", logLevelName(level))
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "LOG-LEVEL-UPDATED", "settings", "",
+		map[string]any{"previousLevel": logLevelName(previousLevel), "level": logLevelName(level)})
+
+	w.WriteHeader(http.StatusNoContent)
+}
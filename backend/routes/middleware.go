@@ -25,6 +25,7 @@ const (
 	errorKey
 	translatorKey
 	authenticatedUserKey
+	requestIdKey
 )
 
 // Allows the updated logger to be accessed by previous middleware layers
@@ -40,7 +41,9 @@ func setRequestLogger(rootLogger *Logger) mux.MiddlewareFunc {
 			requestId := uuid.New().String()
 			requestLogger := rootLogger.With("requestId", requestId, "clientIp", r.RemoteAddr, "url", r.URL.Path, "method", r.Method)
 
-			r = r.WithContext(context.WithValue(r.Context(), requestLoggerKey, &LoggerTransport{Logger: requestLogger}))
+			ctx := context.WithValue(r.Context(), requestLoggerKey, &LoggerTransport{Logger: requestLogger})
+			ctx = context.WithValue(ctx, requestIdKey, requestId)
+			r = r.WithContext(ctx)
 
 			next.ServeHTTP(w, r)
 		})
@@ -52,6 +55,12 @@ func getRequestLogger(r *http.Request) *Logger {
 	return requestLoggerTransport.Logger
 }
 
+// Returns the id that the request is logged with, so that other records of the request can be matched with its logs
+func getRequestId(r *http.Request) string {
+	requestId, _ := r.Context().Value(requestIdKey).(string)
+	return requestId
+}
+
 // Implementation of http.ResponseWriter so the response status is recorded for logging purposes too!
 // Inherits all methods from http.ResponseWriter except WriteHeader
 type ResponseWriterRecorder struct {
@@ -64,6 +73,11 @@ func (wr *ResponseWriterRecorder) WriteHeader(status int) {
 	wr.status = status
 }
 
+// Allows http.ResponseController to reach the underlying ResponseWriter (e.g. to flush Server-Sent Events)
+func (wr *ResponseWriterRecorder) Unwrap() http.ResponseWriter {
+	return wr.ResponseWriter
+}
+
 // Note: "X-Real-Ip" and "X-Forwarded-For" headers are not used for the clientIp because they can be modified by the client == security risk
 func logRequestCompletion(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -223,7 +237,7 @@ func getAuthenticatedUser(r *http.Request) postgres.User {
 	return user
 }
 
-func verifyAuthorization(authEnforcer casbin.IEnforcer) mux.MiddlewareFunc {
+func verifyAuthorization(authEnforcer casbin.IEnforcer, postgresStore *postgres.PostgresStore) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := getAuthenticatedUser(r)
@@ -238,6 +252,20 @@ func verifyAuthorization(authEnforcer casbin.IEnforcer) mux.MiddlewareFunc {
 				return
 			}
 
+			// Banned users can still read, and log in and out, but cannot make any changes
+			isWrite := r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions
+			if user.Username != "" && isWrite && !strings.HasSuffix(r.URL.Path, "/session") {
+				ban, err := postgresStore.GetActiveUserBan(user.Username)
+				if err != nil {
+					sendToErrorHandlingMiddleware(err, r)
+					return
+				}
+				if ban != nil && ban.Type == "Ban" {
+					sendToErrorHandlingMiddleware(NewUserBannedError(*ban), r)
+					return
+				}
+			}
+
 			reqLogger := getRequestLogger(r)
 			reqLogger.Info("USER-AUTHORISED", "username", user.Username, "resource", r.URL.Path, "method", r.Method)
 
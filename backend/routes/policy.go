@@ -0,0 +1,235 @@
+package routes
+
+import (
+	"backend/httperror"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// An authorization policy ("policy") or role assignment ("role")
+// For role assignments, the subject is the user and the object is the role, and there is no action
+type authPolicy struct {
+	Type    string `json:"type"`
+	Subject string `json:"subject"`
+	Object  string `json:"object"`
+	Action  string `json:"action,omitempty"`
+}
+
+// Lists the policies and role assignments, filtered by subject (exact match) and object (partial match)
+func (router *Router) handleGetPolicies(w http.ResponseWriter, r *http.Request) {
+	subject := r.URL.Query().Get("subject")
+	object := r.URL.Query().Get("object")
+
+	policyRules, err := router.authEnforcer.GetPolicy()
+	if err != nil {
+		sendToErrorHandlingMiddleware(httperror.NewInternalServerError(err), r)
+		return
+	}
+	roleRules, err := router.authEnforcer.GetGroupingPolicy()
+	if err != nil {
+		sendToErrorHandlingMiddleware(httperror.NewInternalServerError(err), r)
+		return
+	}
+
+	policies := []authPolicy{}
+	for _, rule := range policyRules {
+		policies = append(policies, authPolicy{Type: "policy", Subject: rule[0], Object: rule[1], Action: rule[2]})
+	}
+	for _, rule := range roleRules {
+		policies = append(policies, authPolicy{Type: "role", Subject: rule[0], Object: rule[1]})
+	}
+
+	filteredPolicies := []authPolicy{}
+	for _, policy := range policies {
+		if subject != "" && policy.Subject != subject {
+			continue
+		}
+		if object != "" && !strings.Contains(policy.Object, object) {
+			continue
+		}
+		filteredPolicies = append(filteredPolicies, policy)
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("POLICIES-FETCHED", "subject", subject, "object", object)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(filteredPolicies)
+}
+
+// Explains whether a user may send a request, and which policy allowed it
+func (router *Router) handleExplainPolicyDecision(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Username string `validate:"omitempty,notBlank" name:"username"` // Leave out to explain the decision for logged out users
+		Path     string `validate:"required,notBlank,startswith=/api/" name:"path"`
+		Method   string `validate:"required,oneof=GET POST PUT DELETE" name:"method"`
+	}
+
+	type responseBody struct {
+		Allowed       bool        `json:"allowed"`
+		MatchedPolicy *authPolicy `json:"matchedPolicy"` // null if the request is not allowed
+		Roles         []string    `json:"roles"`         // The roles of the user, including inherited ones
+	}
+
+	input := requestInput{
+		Username: r.URL.Query().Get("username"),
+		Path:     r.URL.Query().Get("path"),
+		Method:   r.URL.Query().Get("method"),
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	allowed, explanation, err := router.authEnforcer.EnforceEx(input.Username, input.Path, input.Method)
+	if err != nil {
+		sendToErrorHandlingMiddleware(httperror.NewInternalServerError(err), r)
+		return
+	}
+	roles, err := router.authEnforcer.GetImplicitRolesForUser(input.Username)
+	if err != nil {
+		sendToErrorHandlingMiddleware(httperror.NewInternalServerError(err), r)
+		return
+	}
+
+	response := responseBody{Allowed: allowed, Roles: roles}
+	if allowed && len(explanation) == 3 {
+		response.MatchedPolicy = &authPolicy{Type: "policy", Subject: explanation[0], Object: explanation[1], Action: explanation[2]}
+	}
+	if response.Roles == nil {
+		response.Roles = []string{}
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("POLICY-DECISION-EXPLAINED", "username", input.Username, "path", input.Path, "method", input.Method, "allowed", allowed)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// The function "getPolicyParams" is an abstraction for handleAddPolicy and handleRemovePolicy
+// It is not directly attached to any endpoint
+func (router *Router) getPolicyParams(r *http.Request) (*authPolicy, string, error) {
+	type requestInput struct {
+		Type    string `validate:"required,oneof=policy role" name:"type"`
+		Subject string `validate:"required,notBlank,max=300" name:"subject"`
+		Object  string `validate:"required,notBlank,max=300" name:"object"`
+		Action  string `validate:"omitempty,oneof=GET POST PUT DELETE" name:"action"`
+		Reason  string `validate:"max=500" name:"reason"`
+	}
+
+	var input requestInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		return nil, "", ErrInvalidJSON
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		return nil, "", err
+	}
+	validationErrors := map[string]string{}
+	if input.Type == "policy" && !strings.HasPrefix(input.Object, "/api/{version}/") {
+		validationErrors["object"] = `The object must be a path that starts with "/api/{version}/"`
+	}
+	// Users are only granted policies through their roles
+	if input.Type == "policy" && !slices.Contains(specialPolicySubjects, input.Subject) && !strings.HasPrefix(input.Subject, rolePrefix) {
+		validationErrors["subject"] = `The subject must be a role that starts with "role:", "*", "authenticated" or "owner"`
+	}
+	if input.Type == "role" && !strings.HasPrefix(input.Object, rolePrefix) {
+		validationErrors["object"] = `The role must start with "role:"`
+	}
+	if input.Type == "policy" && input.Action == "" {
+		validationErrors["action"] = "You must provide an action"
+	}
+	if input.Type == "role" && input.Action != "" {
+		validationErrors["action"] = "Role assignments do not have an action"
+	}
+	if len(validationErrors) > 0 {
+		return nil, "", NewInputValidationError(validationErrors)
+	}
+
+	policy := authPolicy{
+		Type:    input.Type,
+		Subject: input.Subject,
+		Object:  input.Object,
+		Action:  input.Action,
+	}
+	return &policy, input.Reason, nil
+}
+
+func (router *Router) handleAddPolicy(w http.ResponseWriter, r *http.Request) {
+	policy, reason, err := router.getPolicyParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// If there is no error from getPolicyParams, policy is guaranteed to be non-nil,
+	// so dereferencing can occur safely
+	var added bool
+	if policy.Type == "policy" {
+		added, err = router.authEnforcer.AddPolicy(policy.Subject, policy.Object, policy.Action)
+	} else {
+		added, err = router.authEnforcer.AddGroupingPolicy(policy.Subject, policy.Object)
+	}
+	if err != nil {
+		sendToErrorHandlingMiddleware(httperror.NewInternalServerError(err), r)
+		return
+	}
+	if !added {
+		sendToErrorHandlingMiddleware(ErrPolicyAlreadyExists, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("POLICY-ADDED", "type", policy.Type, "subject", policy.Subject, "object", policy.Object, "action", policy.Action)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "POLICY-ADDED", "policy", policy.Subject,
+		map[string]any{"type": policy.Type, "object": policy.Object, "action": policy.Action, "reason": reason})
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (router *Router) handleRemovePolicy(w http.ResponseWriter, r *http.Request) {
+	policy, reason, err := router.getPolicyParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// If there is no error from getPolicyParams, policy is guaranteed to be non-nil,
+	// so dereferencing can occur safely
+	var removed bool
+	if policy.Type == "policy" {
+		removed, err = router.authEnforcer.RemovePolicy(policy.Subject, policy.Object, policy.Action)
+	} else {
+		removed, err = router.authEnforcer.RemoveGroupingPolicy(policy.Subject, policy.Object)
+	}
+	if err != nil {
+		sendToErrorHandlingMiddleware(httperror.NewInternalServerError(err), r)
+		return
+	}
+	if !removed {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("POLICY-REMOVED", "type", policy.Type, "subject", policy.Subject, "object", policy.Object, "action", policy.Action)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "POLICY-REMOVED", "policy", policy.Subject,
+		map[string]any{"type": policy.Type, "object": policy.Object, "action": policy.Action, "reason": reason})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"backend/postgres"
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+)
+
+// An audit log that cannot record anything, e.g. because the database is unreachable
+type failingAuditLog struct{}
+
+func (failingAuditLog) CreateAuditEvent(event postgres.AuditEvent) error {
+	return errors.New("audit log unavailable")
+}
+
+func newTestPolicyRouter(t *testing.T) *Router {
+	universalTranslator := NewUniversalTranslator()
+	validate, err := NewValidator(universalTranslator)
+	if err != nil {
+		t.Fatalf("could not instantiate the validator: %s", err)
+	}
+
+	m, err := model.NewModelFromString(AuthModel)
+	if err != nil {
+		t.Fatalf("could not load the authorization model: %s", err)
+	}
+	authEnforcer, err := casbin.NewSyncedEnforcer(m)
+	if err != nil {
+		t.Fatalf("could not instantiate the enforcer: %s", err)
+	}
+
+	return &Router{
+		auditLog:            failingAuditLog{},
+		universalTranslator: universalTranslator,
+		validate:            validate,
+		authEnforcer:        authEnforcer,
+	}
+}
+
+// Sets up the request the way the middlewares do, for an admin
+func newTestPolicyRequest(router *Router, method string, body string) (*http.Request, *ErrorTransport, *bytes.Buffer) {
+	var output bytes.Buffer
+	logger := NewRootLogger(&output, LoggerOptions{Level: slog.LevelDebug})
+	translator, _ := router.universalTranslator.GetTranslator("en")
+	errorTransport := &ErrorTransport{}
+
+	r := httptest.NewRequest(method, "/api/v1/policies", strings.NewReader(body))
+	ctx := context.WithValue(r.Context(), requestLoggerKey, &LoggerTransport{Logger: logger})
+	ctx = context.WithValue(ctx, errorKey, errorTransport)
+	ctx = context.WithValue(ctx, translatorKey, translator)
+	ctx = context.WithValue(ctx, authenticatedUserKey, postgres.User{Username: "Jerry_the_mouse"})
+
+	return r.WithContext(ctx), errorTransport, &output
+}
+
+// The policy change has already been made when it is audited, so a failure to audit it must not fail the request
+func TestPolicyChangesSucceedWhenTheAuditLogFails(t *testing.T) {
+	router := newTestPolicyRouter(t)
+	body := `{"type": "policy", "subject": "role:moderator", "object": "/api/{version}/audit-events", "action": "GET", "reason": "Testing"}`
+
+	r, errorTransport, output := newTestPolicyRequest(router, "POST", body)
+	w := httptest.NewRecorder()
+	router.handleAddPolicy(w, r)
+
+	if errorTransport.Error != nil || w.Code != http.StatusCreated {
+		t.Fatalf("adding the policy responded with %d and error %v, want 201", w.Code, errorTransport.Error)
+	}
+	if added, _ := router.authEnforcer.HasPolicy("role:moderator", "/api/{version}/audit-events", "GET"); !added {
+		t.Error("the policy was not added")
+	}
+	if !strings.Contains(output.String(), "AUDIT-EVENT-FAILED") || !strings.Contains(output.String(), "POLICY-ADDED") {
+		t.Errorf("the failure to audit the change was not logged: %s", output.String())
+	}
+
+	r, errorTransport, output = newTestPolicyRequest(router, "DELETE", body)
+	w = httptest.NewRecorder()
+	router.handleRemovePolicy(w, r)
+
+	if errorTransport.Error != nil || w.Code != http.StatusNoContent {
+		t.Fatalf("removing the policy responded with %d and error %v, want 204", w.Code, errorTransport.Error)
+	}
+	if removed, _ := router.authEnforcer.HasPolicy("role:moderator", "/api/{version}/audit-events", "GET"); removed {
+		t.Error("the policy was not removed")
+	}
+	if !strings.Contains(output.String(), "AUDIT-EVENT-FAILED") || !strings.Contains(output.String(), "POLICY-REMOVED") {
+		t.Errorf("the failure to audit the change was not logged: %s", output.String())
+	}
+}
@@ -3,8 +3,8 @@ package routes
 import (
 	"backend/postgres"
 	"encoding/json"
-	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -46,21 +46,79 @@ func (router *Router) handleGetPost(w http.ResponseWriter, r *http.Request) {
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("POST-FETCHED", "postId", post.Id)
 
-	w.WriteHeader(http.StatusCreated)
+	writeJSONWithVersionedConditionalGet(w, r, post, post.Version)
+}
+
+const defaultFeedPageSize = 20
+
+const relatedPostsLimit = 5
+const relatedPostsCacheTTL = 5 * time.Minute
+const relatedPostsCacheSize = 1000
+
+func newRelatedPostsCache() *ttlCache[[]postgres.RelatedPost] {
+	return newTTLCache[[]postgres.RelatedPost](relatedPostsCacheTTL, relatedPostsCacheSize)
+}
+
+// Gets the published posts that are most related to the post
+// The results do not depend on the viewer, so they are cached for all viewers for a short time
+func (router *Router) handleGetRelatedPosts(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		PostId string `validate:"required,notBlank,uuid4" name:"post id"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		PostId: vars["postId"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	relatedPosts, cached := router.relatedPostsCache.Get(input.PostId)
+	if !cached {
+		// Make DB query
+		relatedPosts, err = router.postgresStore.GetRelatedPosts(input.PostId, relatedPostsLimit)
+		if err != nil {
+			sendToErrorHandlingMiddleware(err, r)
+			return
+		}
+		if relatedPosts == nil {
+			sendToErrorHandlingMiddleware(Err404NotFound, r)
+			return
+		}
+		router.relatedPostsCache.Set(input.PostId, relatedPosts)
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("RELATED-POSTS-FETCHED", "postId", input.PostId, "cached", cached)
+
 	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
 
-	json.NewEncoder(w).Encode(post)
+	json.NewEncoder(w).Encode(relatedPosts)
 }
 
 // The function "getPosts" is an abstraction for all route handlers that involve fetching posts.
 // It is not directly attached to any endpoint.
 type getPostsRequestInput struct {
 	Query string `validate:"omitempty,notBlank" name:"query"`
+	Highlight string `validate:"omitempty,boolean" name:"highlight"` // Only used if there is a query
 	Tags []string `validate:"omitempty,notBlank" name:"tags"`
 	Author string `validate:"omitempty,notBlank" name:"author"`
 	LikedBy string `validate:"omitempty,notBlank" name:"liked by"`
+	BookmarkedBy string `validate:"omitempty,notBlank" name:"bookmarked by"`
+	FeedOf string `validate:"omitempty,notBlank" name:"feed of"`
 	Statuses []string `validate:"omitempty,dive,oneof=Draft Published Deleted" name:"statuses"`
-	SortBy string `validate:"omitempty,oneof=Newest Popular Relevance" name:"sort by"`
+	SortBy string `validate:"omitempty,oneof=Newest Popular Relevance Hot Top" name:"sort by"`
+	Period string `validate:"omitempty,oneof=day week month year" name:"period"` // Only used by the "Top" sort
+	Page int `validate:"min=1" name:"page"`
+	PageSize int `validate:"min=0,max=100" name:"page size"` // A page size of 0 means no pagination
+	ListingFilter listingFilterInput
 }
 
 func (router *Router) getPosts(w http.ResponseWriter, r *http.Request, input getPostsRequestInput) {
@@ -72,29 +130,64 @@ func (router *Router) getPosts(w http.ResponseWriter, r *http.Request, input get
 		return
 	}
 
-	// Make DB query
 	user := getAuthenticatedUser(r)
-	posts, err := router.postgresStore.GetPosts(user.Username, input.Author, input.Statuses, input.Query, input.Tags, input.LikedBy, input.SortBy)
+	searchQuery, statuses, err := getSearchQuery(input.Query, user, input.Statuses, true)
 	if err != nil {
 		sendToErrorHandlingMiddleware(err, r)
 		return
 	}
 
-	requestLogger := getRequestLogger(r)
-	requestLogger.Info("POSTS-FETCHED", "query", input.Query, "tags", input.Tags, "author", input.Author, "likedBy", input.LikedBy, "sortBy", input.SortBy)
+	// Make DB query
+	limit, offset := getLimitAndOffset(input.Page, input.PageSize)
+	filter := postgres.PostFilter{
+		Author: input.Author,
+		Statuses: statuses,
+		SearchQuery: searchQuery,
+		Tags: input.Tags,
+		LikedBy: input.LikedBy,
+		BookmarkedBy: input.BookmarkedBy,
+		FeedOf: input.FeedOf,
+		SortBy: input.SortBy,
+		Period: input.Period,
+		Highlight: input.Highlight == "true",
+		ListingFilter: input.ListingFilter.toListingFilter(),
+		Limit: limit,
+		Offset: offset,
+	}
+	posts, err := router.postgresStore.GetPosts(user.Username, filter)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("content-type", "application/json")
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("POSTS-FETCHED", "query", input.Query, "highlight", input.Highlight, "tags", input.Tags, "author", input.Author, "likedBy", input.LikedBy, "bookmarkedBy", input.BookmarkedBy, "feedOf", input.FeedOf, "sortBy", input.SortBy, "period", input.Period, "page", input.Page, "pageSize", input.PageSize)
 
-	json.NewEncoder(w).Encode(posts)
+	writeJSONWithConditionalGet(w, r, posts)
 }
 
 func (router *Router) handleGetPosts(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm() // Parses the query params in such a way that values belonging to the same key are merged into an array
+	page, pageSize, err := getPaginationParams(r, 0)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	listingFilter, err := getListingFilterParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
 	input := getPostsRequestInput{
 		Query: r.URL.Query().Get("query"),
+		Highlight: r.URL.Query().Get("highlight"),
 		Tags: r.Form["tag"],
 		SortBy: r.URL.Query().Get("sortBy"),
+		Period: r.URL.Query().Get("period"),
+		Page: page,
+		PageSize: pageSize,
+		ListingFilter: listingFilter,
 		Statuses: []string{"Published"},
 	}
 
@@ -105,10 +198,26 @@ func (router *Router) handleGetMyPosts(w http.ResponseWriter, r *http.Request) {
 	user := getAuthenticatedUser(r)
 	r.ParseForm() // Parses the query params in such a way that values belonging to the same key are merged into an array
 
+	page, pageSize, err := getPaginationParams(r, 0)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	listingFilter, err := getListingFilterParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
 	input := getPostsRequestInput{
 		Query: r.URL.Query().Get("query"),
+		Highlight: r.URL.Query().Get("highlight"),
 		Tags: r.Form["tag"],
 		SortBy: r.URL.Query().Get("sortBy"),
+		Period: r.URL.Query().Get("period"),
+		Page: page,
+		PageSize: pageSize,
+		ListingFilter: listingFilter,
 		Author: user.Username,
 		Statuses: []string{"Published", "Deleted"}, // Only show published and deleted posts
 	}
@@ -120,10 +229,26 @@ func (router *Router) handleGetMyDrafts(w http.ResponseWriter, r *http.Request)
 	user := getAuthenticatedUser(r)
 	r.ParseForm() // Parses the query params in such a way that values belonging to the same key are merged into an array
 
+	page, pageSize, err := getPaginationParams(r, 0)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	listingFilter, err := getListingFilterParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
 	input := getPostsRequestInput{
 		Query: r.URL.Query().Get("query"),
+		Highlight: r.URL.Query().Get("highlight"),
 		Tags: r.Form["tag"],
 		SortBy: r.URL.Query().Get("sortBy"),
+		Period: r.URL.Query().Get("period"),
+		Page: page,
+		PageSize: pageSize,
+		ListingFilter: listingFilter,
 		Author: user.Username,
 		Statuses: []string{"Draft"},
 	}
@@ -135,10 +260,26 @@ func (router *Router) handleGetLikedPosts(w http.ResponseWriter, r *http.Request
 	user := getAuthenticatedUser(r)
 	r.ParseForm() // Parses the query params in such a way that values belonging to the same key are merged into an array
 
+	page, pageSize, err := getPaginationParams(r, 0)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	listingFilter, err := getListingFilterParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
 	input := getPostsRequestInput{
 		Query: r.URL.Query().Get("query"),
+		Highlight: r.URL.Query().Get("highlight"),
 		Tags: r.Form["tag"],
 		SortBy: r.URL.Query().Get("sortBy"),
+		Period: r.URL.Query().Get("period"),
+		Page: page,
+		PageSize: pageSize,
+		ListingFilter: listingFilter,
 		LikedBy: user.Username,
 		Statuses: []string{"Published", "Deleted"},
 	}
@@ -146,6 +287,64 @@ func (router *Router) handleGetLikedPosts(w http.ResponseWriter, r *http.Request
 	router.getPosts(w, r, input)
 }
 
+// Gets the published posts with the tags and authors that the user follows
+func (router *Router) handleGetFeed(w http.ResponseWriter, r *http.Request) {
+	user := getAuthenticatedUser(r)
+	page, pageSize, err := getPaginationParams(r, defaultFeedPageSize)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	listingFilter, err := getListingFilterParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	input := getPostsRequestInput{
+		SortBy: r.URL.Query().Get("sortBy"),
+		Period: r.URL.Query().Get("period"),
+		Page: page,
+		PageSize: pageSize,
+		ListingFilter: listingFilter,
+		FeedOf: user.Username,
+		Statuses: []string{"Published"},
+	}
+
+	router.getPosts(w, r, input)
+}
+
+func (router *Router) handleGetBookmarkedPosts(w http.ResponseWriter, r *http.Request) {
+	user := getAuthenticatedUser(r)
+	r.ParseForm() // Parses the query params in such a way that values belonging to the same key are merged into an array
+
+	page, pageSize, err := getPaginationParams(r, 0)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	listingFilter, err := getListingFilterParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	input := getPostsRequestInput{
+		Query: r.URL.Query().Get("query"),
+		Highlight: r.URL.Query().Get("highlight"),
+		Tags: r.Form["tag"],
+		SortBy: r.URL.Query().Get("sortBy"),
+		Period: r.URL.Query().Get("period"),
+		Page: page,
+		PageSize: pageSize,
+		ListingFilter: listingFilter,
+		BookmarkedBy: user.Username,
+		Statuses: []string{"Published", "Deleted", "Draft"}, // Users can bookmark their own drafts
+	}
+
+	router.getPosts(w, r, input)
+}
+
 // The function "getPostParams" is an abstraction for handleCreatePost and handleUpdatePost
 // It is not directly attached to any endpoint
 func (router *Router) getPostParams(r *http.Request) (*postgres.Post, error) {
@@ -195,28 +394,19 @@ func (router *Router) handleCreatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The posts of shadow-banned users are not announced
 	// If there is no error from getPostParams, post is guaranteed to be non-nil,
 	// so dereferencing can occur safely
-	err = router.postgresStore.CreatePost(*post) 
+	var webhookEvent *postgres.WebhookEvent
+	if post.Status == "Published" && !router.isShadowBanned(r, post.Author) {
+		webhookEvent = newPostWebhookEvent("post.published", *post)
+	}
+	err = router.postgresStore.CreatePost(*post, webhookEvent) 
 	if err != nil {
 		sendToErrorHandlingMiddleware(err, r)
 		return
 	}
 
-	// Add authorisation rules that allow the author to update/delete the post
-	var authenticatedPolicies = [][]string{
-		{post.Author, fmt.Sprintf("/api/{version}/posts/%s", post.Id), "PUT"}, // Auth to update the post
-		{post.Author, fmt.Sprintf("/api/{version}/posts/%s/conversion", post.Id), "POST"}, // Auth to convert a draft to a post
-		{post.Author, fmt.Sprintf("/api/{version}/posts/%s", post.Id), "DELETE"}, // Auth to delete the post
-	}
-
-	err = addAuthPolicies(authenticatedPolicies, router.authEnforcer)
-	if (err != nil) {
-		sendToErrorHandlingMiddleware(err, r)
-		return		
-	}
-
-
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("POST-CREATED", "postId", post.Id)
 
@@ -230,13 +420,28 @@ func (router *Router) handleUpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The post is only updated if it has not been changed since the version that the user edited
+	post.Version, err = getIfMatchVersion(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
 	// If there is no error from getPostParams, post is guaranteed to be non-nil,
 	// so dereferencing can occur safely
-	err = router.postgresStore.UpdatePost(*post)
+	version, err := router.postgresStore.UpdatePost(*post)
+	if version > 0 {
+		// On a stale version error, this is the current version
+		w.Header().Set("ETag", versionETag(version))
+	}
 	if err != nil {
 		sendToErrorHandlingMiddleware(err, r)
 		return
 	}
+	if version == 0 {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
 
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("POST-UPDATED", "postId", post.Id)
@@ -251,13 +456,33 @@ func (router *Router) handleUpdateDraftToPost(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// The draft is only published if it has not been changed since the version that the user edited
+	post.Version, err = getIfMatchVersion(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// The posts of shadow-banned users are not announced
 	// If there is no error from getPostParams, post is guaranteed to be non-nil,
 	// so dereferencing can occur safely	
-	err = router.postgresStore.UpdateDraftToPost(*post)
+	var webhookEvent *postgres.WebhookEvent
+	if !router.isShadowBanned(r, post.Author) {
+		webhookEvent = newPostWebhookEvent("post.published", *post)
+	}
+	version, err := router.postgresStore.UpdateDraftToPost(*post, webhookEvent)
+	if version > 0 {
+		// On a stale version error, this is the current version
+		w.Header().Set("ETag", versionETag(version))
+	}
 	if err != nil {
 		sendToErrorHandlingMiddleware(err, r)
 		return
 	}
+	if version == 0 {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
 
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("UPDATED-DRAFT-TO-POST", "postId", post.Id)
@@ -283,8 +508,21 @@ func (router *Router) handleDeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Fetch the post before deleting it because soft deletion clears its title and tags,
+	// which are needed to notify webhooks
+	post, err := router.postgresStore.GetPostById("", input.Id)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	var webhookEvent *postgres.WebhookEvent
+	if post != nil && post.Status == "Published" {
+		webhookEvent = newPostWebhookEvent("post.deleted", *post)
+	}
+
 	// Make DB query
-	err = router.postgresStore.SoftDeletePost(input.Id)
+	err = router.postgresStore.SoftDeletePost(input.Id, webhookEvent)
 	if err != nil {
 		sendToErrorHandlingMiddleware(err, r)
 		return
@@ -292,6 +530,7 @@ func (router *Router) handleDeletePost(w http.ResponseWriter, r *http.Request) {
 
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("POST-SOFT-DELETED", "postId", input.Id)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "POST-SOFT-DELETED", "post", input.Id, nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -335,6 +574,8 @@ func (router *Router) handleUpsertPostVote(w http.ResponseWriter, r *http.Reques
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("POST-VOTE-UPSERTED", "postId", postVote.PostId, "viewer", postVote.Viewer)
 
+	router.publishPostVoteCount(r, postVote.PostId)
+
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -371,21 +612,83 @@ func (router *Router) handleDeletePostVote(w http.ResponseWriter, r *http.Reques
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("POST-VOTE-DELETED", "postId", postVote.PostId, "viewer", postVote.Viewer)
 
+	router.publishPostVoteCount(r, postVote.PostId)
+
 	w.WriteHeader(http.StatusOK)
 }
 
-func (router *Router) handleGetTags(w http.ResponseWriter, r *http.Request) {
-	tags, err := router.postgresStore.GetTags()
+func (router *Router) handleUpsertPostBookmark(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		PostId string `validate:"required,notBlank,uuid4" name:"id"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		PostId: vars["postId"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	user := getAuthenticatedUser(r)
+	postBookmark := postgres.PostBookmark{
+		PostId: input.PostId,
+		Viewer: user.Username,
+	}
+	found, err := router.postgresStore.UpsertPostBookmark(postBookmark)
 	if err != nil {
 		sendToErrorHandlingMiddleware(err, r)
 		return
 	}
+	if !found {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
 
 	requestLogger := getRequestLogger(r)
-	requestLogger.Info("TAGS-FETCHED")
+	requestLogger.Info("POST-BOOKMARK-UPSERTED", "postId", postBookmark.PostId, "viewer", postBookmark.Viewer)
 
 	w.WriteHeader(http.StatusOK)
-	w.Header().Add("content-type", "application/json")
+}
+
+func (router *Router) handleDeletePostBookmark(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		PostId string `validate:"required,notBlank,uuid4" name:"id"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		PostId: vars["postId"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	user := getAuthenticatedUser(r)
+	postBookmark := postgres.PostBookmark{
+		PostId: input.PostId,
+		Viewer: user.Username,
+	}
+	err = router.postgresStore.DeletePostBookmark(postBookmark)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("POST-BOOKMARK-DELETED", "postId", postBookmark.PostId, "viewer", postBookmark.Viewer)
 
-	json.NewEncoder(w).Encode(tags)
-}
\ No newline at end of file
+	w.WriteHeader(http.StatusOK)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 
+	"backend/events"
 	"backend/postgres"
 )
 
@@ -18,22 +19,28 @@ import (
 type Router struct {
 	*mux.Router
 	postgresStore       *postgres.PostgresStore
+	auditLog            auditLog
 	universalTranslator *ut.UniversalTranslator
 	validate            *validator.Validate
 	rootLogger          *Logger
 	authEnforcer        casbin.IEnforcer
+	eventHub            *events.Hub
+	relatedPostsCache   *ttlCache[[]postgres.RelatedPost]
 }
 
-func NewRouter(postgres *postgres.PostgresStore, universalTranslator *ut.UniversalTranslator, validate *validator.Validate, rootLogger *Logger, authEnforcer casbin.IEnforcer) http.Handler {
+func NewRouter(postgres *postgres.PostgresStore, universalTranslator *ut.UniversalTranslator, validate *validator.Validate, rootLogger *Logger, authEnforcer casbin.IEnforcer, eventHub *events.Hub) http.Handler {
 	r := mux.NewRouter()
 
 	router := &Router{
 		Router:              r,
 		postgresStore:       postgres,
+		auditLog:            postgres,
 		universalTranslator: universalTranslator,
 		validate:            validate,
 		rootLogger:          rootLogger,
 		authEnforcer:        authEnforcer,
+		eventHub:            eventHub,
+		relatedPostsCache:   newRelatedPostsCache(),
 	}
 
 	// Logging middleware wraps around error handling middleware because an error in logging has zero impact on the user
@@ -42,18 +49,55 @@ func NewRouter(postgres *postgres.PostgresStore, universalTranslator *ut.Univers
 	router.Use(errorHandling)
 	router.Use(setTranslator(router.universalTranslator))
 	router.Use(authenticateUser)
-	router.Use(verifyAuthorization(router.authEnforcer))
+	registerAuthFunctions(router.authEnforcer, router.postgresStore)
+	router.Use(verifyAuthorization(router.authEnforcer, router.postgresStore))
 
 	apiRouter := r.PathPrefix("/api/v1").Subrouter()
 	apiRouter.HandleFunc("/session", router.handleLogin).Methods("POST")
 	apiRouter.HandleFunc("/session", router.handleLogout).Methods("DELETE")
-	apiRouter.HandleFunc("/tags", router.handleGetTags).Methods("GET") // Gets all tags of all posts
+	apiRouter.HandleFunc("/tags", router.handleGetTags).Methods("GET") // Gets the tag catalogue with the post count of each tag
+	apiRouter.HandleFunc("/bans", router.handleGetUserBans).Methods("GET") // Only for moderators
+	apiRouter.HandleFunc("/audit-events", router.handleGetAuditEvents).Methods("GET") // Only for admins
+	apiRouter.HandleFunc("/log-level", router.handleGetLogLevel).Methods("GET") // Only for admins
+	apiRouter.HandleFunc("/log-level", router.handleUpdateLogLevel).Methods("PUT") // Only for admins. Only affects the instance that handles the request
+	apiRouter.HandleFunc("/settings", router.handleGetSettings).Methods("GET")
+	apiRouter.HandleFunc("/settings", router.handleUpdateSettings).Methods("PUT") // Only for admins
+	apiRouter.HandleFunc("/events", router.handleGetEvents).Methods("GET") // Server-Sent Events stream of live updates
+	apiRouter.HandleFunc("/search", router.handleSearch).Methods("GET") // Relevance-ranked search of posts, comments, tags and users
+	apiRouter.HandleFunc("/search/suggest", router.handleGetSearchSuggestions).Methods("GET") // Autocomplete of post titles and tags
+
+	// Atom and RSS 2.0 feeds of the newest published posts, e.g. /feeds/posts.atom or /feeds/tags/Question.rss
+	feedRouter := apiRouter.PathPrefix("/feeds").Subrouter()
+	feedRouter.HandleFunc("/posts.{format:atom|rss}", router.handleGetPostsFeed).Methods("GET")
+	feedRouter.HandleFunc("/tags/{tag}.{format:atom|rss}", router.handleGetTagFeed).Methods("GET")
+	feedRouter.HandleFunc("/users/{username}.{format:atom|rss}", router.handleGetAuthorFeed).Methods("GET")
+
+	tagRouter := apiRouter.PathPrefix("/tags/{tag}").Subrouter()
+	tagRouter.HandleFunc("", router.handleCreateTag).Methods("POST") // Only for moderators
+	tagRouter.HandleFunc("", router.handleUpdateTag).Methods("PUT") // Only for moderators. Also renames and retires the tag
+	tagRouter.HandleFunc("/merge", router.handleMergeTag).Methods("POST") // Only for moderators
+	tagRouter.HandleFunc("/aliases/{alias}", router.handleUpsertTagAlias).Methods("PUT") // Only for moderators
+	tagRouter.HandleFunc("/aliases/{alias}", router.handleDeleteTagAlias).Methods("DELETE") // Only for moderators
+	tagRouter.HandleFunc("/followers", router.handleGetTagFollowerCount).Methods("GET")
+	tagRouter.HandleFunc("/follow", router.handleUpsertTagFollow).Methods("PUT") // Endpoint for following a tag
+	tagRouter.HandleFunc("/follow", router.handleDeleteTagFollow).Methods("DELETE") // Endpoint for following a tag
 
 	userRouter := apiRouter.PathPrefix("/users/{username}").Subrouter()
 	userRouter.HandleFunc("", router.handleCreateUser).Methods("POST")
 	userRouter.HandleFunc("/posts", router.handleGetMyPosts).Methods("GET")
 	userRouter.HandleFunc("/drafts", router.handleGetMyDrafts).Methods("GET")
 	userRouter.HandleFunc("/liked-posts", router.handleGetLikedPosts).Methods("GET")
+	userRouter.HandleFunc("/bookmarks", router.handleGetBookmarkedPosts).Methods("GET")
+	userRouter.HandleFunc("/feed", router.handleGetFeed).Methods("GET") // Gets the posts with the tags & authors that the user follows
+	userRouter.HandleFunc("/following", router.handleGetFollowing).Methods("GET")
+	userRouter.HandleFunc("/followers", router.handleGetUserFollowerCount).Methods("GET")
+	userRouter.HandleFunc("/follow", router.handleUpsertUserFollow).Methods("PUT") // Endpoint for following a user
+	userRouter.HandleFunc("/follow", router.handleDeleteUserFollow).Methods("DELETE") // Endpoint for following a user
+	userRouter.HandleFunc("/blocked", router.handleGetBlockedUsers).Methods("GET") // Gets the users that the user has blocked
+	userRouter.HandleFunc("/block", router.handleUpsertUserBlock).Methods("PUT") // Endpoint for blocking a user
+	userRouter.HandleFunc("/block", router.handleDeleteUserBlock).Methods("DELETE") // Endpoint for unblocking a user
+	userRouter.HandleFunc("/ban", router.handleUpsertUserBan).Methods("PUT") // Only for moderators. Replaces any existing ban
+	userRouter.HandleFunc("/ban", router.handleDeleteUserBan).Methods("DELETE") // Only for moderators
 	userRouter.HandleFunc("/comments", router.handleGetMyComments).Methods("GET")
 	userRouter.HandleFunc("/liked-comments", router.handleGetLikedComments).Methods("GET")
 
@@ -65,8 +109,11 @@ func NewRouter(postgres *postgres.PostgresStore, universalTranslator *ut.Univers
 	postRouter.HandleFunc("/{postId}/conversion", router.handleUpdateDraftToPost).Methods("POST")
 	postRouter.HandleFunc("/{postId}", router.handleDeletePost).Methods("DELETE")
 	postRouter.HandleFunc("/{postId}/comments", router.handleGetCommentsByPostId).Methods("GET")
+	postRouter.HandleFunc("/{postId}/related", router.handleGetRelatedPosts).Methods("GET")
 	postRouter.HandleFunc("/{postId}/vote", router.handleUpsertPostVote).Methods("PUT") // Endpoint for voting on a post
 	postRouter.HandleFunc("/{postId}/vote", router.handleDeletePostVote).Methods("DELETE") // Endpoint for voting on a post
+	postRouter.HandleFunc("/{postId}/bookmark", router.handleUpsertPostBookmark).Methods("PUT") // Endpoint for bookmarking a post
+	postRouter.HandleFunc("/{postId}/bookmark", router.handleDeletePostBookmark).Methods("DELETE") // Endpoint for bookmarking a post
  
 	commentRouter := apiRouter.PathPrefix("/comments").Subrouter()
 	commentRouter.HandleFunc("/{commentId}", router.handleCreateComment).Methods("POST")
@@ -75,15 +122,30 @@ func NewRouter(postgres *postgres.PostgresStore, universalTranslator *ut.Univers
 	commentRouter.HandleFunc("/{commentId}/vote", router.handleUpsertCommentVote).Methods("PUT") // Endpoint for voting on a comment
 	commentRouter.HandleFunc("/{commentId}/vote", router.handleDeleteCommentVote).Methods("DELETE") // Endpoint for voting on a comment
 
+	policyRouter := apiRouter.PathPrefix("/policies").Subrouter() // Only for admins
+	policyRouter.HandleFunc("", router.handleGetPolicies).Methods("GET")
+	policyRouter.HandleFunc("", router.handleAddPolicy).Methods("POST")
+	policyRouter.HandleFunc("", router.handleRemovePolicy).Methods("DELETE")
+	policyRouter.HandleFunc("/explanation", router.handleExplainPolicyDecision).Methods("GET") // Explains whether a user may send a request
+
+	webhookRouter := apiRouter.PathPrefix("/webhooks").Subrouter()
+	webhookRouter.HandleFunc("", router.handleGetWebhooks).Methods("GET")
+	webhookRouter.HandleFunc("/{webhookId}", router.handleCreateWebhook).Methods("POST")
+	webhookRouter.HandleFunc("/{webhookId}", router.handleUpdateWebhook).Methods("PUT")
+	webhookRouter.HandleFunc("/{webhookId}", router.handleDeleteWebhook).Methods("DELETE")
+	webhookRouter.HandleFunc("/{webhookId}/deliveries", router.handleGetWebhookDeliveries).Methods("GET")
+	webhookRouter.HandleFunc("/{webhookId}/deliveries/{deliveryId}/redelivery", router.handleRedeliverWebhookDelivery).Methods("POST")
+
 	router.NotFoundHandler = setRequestLogger(router.rootLogger)(errorHandling(http.HandlerFunc(router.handleNotFound))) // Custom 404 handler
 
 	// Accept requests that come from the frontend domain
-	headers := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
+	headers := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", "If-Match"})
+	exposedHeaders := handlers.ExposedHeaders([]string{"ETag"})
 	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
 	origins := handlers.AllowedOrigins([]string{fmt.Sprintf("%v://%v", os.Getenv("FRONTEND_PROTOCOL"), os.Getenv("FRONTEND_DOMAIN"))})
 	creds := handlers.AllowCredentials()
 
-	return handlers.CORS(headers, methods, origins, creds)(router)
+	return handlers.CORS(headers, exposedHeaders, methods, origins, creds)(router)
 }
 
 func (router *Router) handleNotFound(w http.ResponseWriter, r *http.Request) {
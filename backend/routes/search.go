@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+const searchSuggestionLimit = 5
+const defaultSearchPageSize = 20
+
+func (router *Router) handleSearch(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Query    string `validate:"required,notBlank,max=200" name:"query"`
+		Page     int    `validate:"min=1" name:"page"`
+		PageSize int    `validate:"min=1,max=100" name:"page size"`
+	}
+
+	page, pageSize, err := getPaginationParams(r, defaultSearchPageSize)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	input := requestInput{
+		Query:    r.URL.Query().Get("q"),
+		Page:     page,
+		PageSize: pageSize,
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	user := getAuthenticatedUser(r)
+	limit, offset := getLimitAndOffset(input.Page, input.PageSize)
+	results, err := router.postgresStore.Search(user.Username, input.Query, limit, offset)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("SEARCH-RESULTS-FETCHED", "query", input.Query, "page", input.Page, "pageSize", input.PageSize)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(results)
+}
+
+func (router *Router) handleGetSearchSuggestions(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Query string `validate:"required,notBlank,max=100" name:"query"`
+	}
+
+	input := requestInput{
+		Query: r.URL.Query().Get("q"),
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	suggestions, err := router.postgresStore.GetSearchSuggestions(input.Query, searchSuggestionLimit)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("SEARCH-SUGGESTIONS-FETCHED", "query", input.Query)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(suggestions)
+}
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"backend/postgres"
+	"encoding/json"
+	"net/http"
+)
+
+func (router *Router) handleGetSettings(w http.ResponseWriter, r *http.Request) {
+	settings, err := router.postgresStore.GetAppSettings()
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("SETTINGS-FETCHED")
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(settings)
+}
+
+func (router *Router) handleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		AllowFreeformTags *bool `validate:"required" name:"allow free-form tags"`
+	}
+
+	var input requestInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(ErrInvalidJSON, r)
+		return
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	settings := postgres.AppSettings{
+		AllowFreeformTags: *input.AllowFreeformTags,
+	}
+	err = router.postgresStore.UpdateAppSettings(settings)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("SETTINGS-UPDATED", "allowFreeformTags", settings.AllowFreeformTags)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "SETTINGS-UPDATED", "settings", "",
+		map[string]any{"allowFreeformTags": settings.AllowFreeformTags})
+
+	w.WriteHeader(http.StatusNoContent)
+}
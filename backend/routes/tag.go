@@ -0,0 +1,241 @@
+package routes
+
+import (
+	"backend/postgres"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+func (router *Router) handleGetTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := router.postgresStore.GetTags()
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("TAGS-FETCHED")
+
+	writeJSONWithConditionalGet(w, r, tags)
+}
+
+func (router *Router) handleCreateTag(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Name        string `validate:"required,notBlank,max=30" name:"name"`
+		Description string `validate:"max=500" name:"description"`
+		Colour      string `validate:"required,hexcolor" name:"colour"`
+		Parent      string `validate:"omitempty,notBlank,max=30" name:"parent"` // No parent means that the tag is a top-level tag
+	}
+
+	var input requestInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(ErrInvalidJSON, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	input.Name = vars["tag"]
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	tag := postgres.Tag{
+		Name:        input.Name,
+		Description: input.Description,
+		Colour:      input.Colour,
+		Parent:      input.Parent,
+	}
+	err = router.postgresStore.CreateTag(tag)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("TAG-CREATED", "tag", tag.Name, "parent", tag.Parent)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "TAG-CREATED", "tag", tag.Name, map[string]any{"parent": tag.Parent})
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// Updates the tag's description, colour and retirement. If a different name is provided, the tag is renamed
+func (router *Router) handleUpdateTag(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		CurrentName string `validate:"required,notBlank,max=30" name:"current name"`
+		Name        string `validate:"required,notBlank,max=30" name:"name"`
+		Description string `validate:"max=500" name:"description"`
+		Colour      string `validate:"required,hexcolor" name:"colour"`
+		Retired     *bool  `validate:"required" name:"retired"`
+		Parent      string `validate:"omitempty,notBlank,max=30" name:"parent"` // No parent means that the tag is a top-level tag
+	}
+
+	var input requestInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(ErrInvalidJSON, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	input.CurrentName = vars["tag"]
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	tag := postgres.Tag{
+		Name:        input.Name,
+		Description: input.Description,
+		Colour:      input.Colour,
+		Retired:     *input.Retired,
+		Parent:      input.Parent,
+	}
+	found, err := router.postgresStore.UpdateTag(input.CurrentName, tag)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	if !found {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("TAG-UPDATED", "currentName", input.CurrentName, "tag", tag.Name, "retired", tag.Retired, "parent", tag.Parent)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "TAG-UPDATED", "tag", input.CurrentName,
+		map[string]any{"name": tag.Name, "retired": tag.Retired, "parent": tag.Parent})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Merges the tag into another tag, e.g. to merge "Qn" into "Question"
+func (router *Router) handleMergeTag(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Source string `validate:"required,notBlank,max=30" name:"tag"`
+		Target string `validate:"required,notBlank,max=30" name:"target tag"`
+	}
+
+	var input requestInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(ErrInvalidJSON, r)
+		return
+	}
+
+	vars := mux.Vars(r)
+	input.Source = vars["tag"]
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	if strings.EqualFold(input.Source, input.Target) {
+		sendToErrorHandlingMiddleware(NewInputValidationError(map[string]string{"target": "A tag cannot be merged into itself"}), r)
+		return
+	}
+
+	// Make DB query
+	found, err := router.postgresStore.MergeTags(input.Source, input.Target)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	if !found {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("TAG-MERGED", "tag", input.Source, "target", input.Target)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "TAG-MERGED", "tag", input.Source, map[string]any{"target": input.Target})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// The function "getTagAliasParams" is an abstraction for handleUpsertTagAlias and handleDeleteTagAlias
+// It is not directly attached to any endpoint
+func (router *Router) getTagAliasParams(r *http.Request) (*postgres.TagAlias, error) {
+	type requestInput struct {
+		Tag   string `validate:"required,notBlank,max=30" name:"tag"`
+		Alias string `validate:"required,notBlank,max=30" name:"alias"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Tag:   vars["tag"],
+		Alias: vars["alias"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return &postgres.TagAlias{
+		Alias: input.Alias,
+		Tag:   input.Tag,
+	}, nil
+}
+
+// Adds a synonym of the tag, e.g. "intern" for "internship"
+func (router *Router) handleUpsertTagAlias(w http.ResponseWriter, r *http.Request) {
+	tagAlias, err := router.getTagAliasParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// If there is no error from getTagAliasParams, tagAlias is guaranteed to be non-nil,
+	// so dereferencing can occur safely
+	err = router.postgresStore.UpsertTagAlias(*tagAlias)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("TAG-ALIAS-UPSERTED", "tag", tagAlias.Tag, "alias", tagAlias.Alias)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "TAG-ALIAS-UPSERTED", "tag", tagAlias.Tag, map[string]any{"alias": tagAlias.Alias})
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (router *Router) handleDeleteTagAlias(w http.ResponseWriter, r *http.Request) {
+	tagAlias, err := router.getTagAliasParams(r)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	err = router.postgresStore.DeleteTagAlias(*tagAlias)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("TAG-ALIAS-DELETED", "tag", tagAlias.Tag, "alias", tagAlias.Alias)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "TAG-ALIAS-DELETED", "tag", tagAlias.Tag, map[string]any{"alias": tagAlias.Alias})
+
+	w.WriteHeader(http.StatusNoContent)
+}
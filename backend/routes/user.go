@@ -3,7 +3,6 @@ package routes
 import (
 	"backend/postgres"
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/alexedwards/argon2id"
@@ -33,6 +32,11 @@ func (router *Router) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		sendToErrorHandlingMiddleware(err, r)
 		return
 	}
+	// Reserved usernames would match the authorization rules of roles and special subjects
+	if isReservedUsername(input.Username) {
+		sendToErrorHandlingMiddleware(NewInputValidationError(map[string]string{"username": "This username is reserved"}), r)
+		return
+	}
 
 	// Hash the password
 	hashedPassword, err := argon2id.CreateHash(input.Password, argon2id.DefaultParams)
@@ -48,35 +52,15 @@ func (router *Router) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Add policies to give authenticated users the authorization to do things (e.g. create a post)
-	var authenticatedPolicies = [][]string{
-		{user.Username, "/api/{version}/posts/{postId}", "POST"}, // Auth to create posts
-		{user.Username, "/api/{version}/posts/{postId}/vote", "PUT"}, // Auth to liked/dislike posts
-		{user.Username, "/api/{version}/posts/{postId}/vote", "DELETE"}, // Auth to liked/dislike posts
-		{user.Username, fmt.Sprintf("/api/{version}/users/%s/posts", user.Username), "GET"}, // Auth to see own posts
-		{user.Username, fmt.Sprintf("/api/{version}/users/%s/drafts", user.Username), "GET"}, // Auth to see own drafts
-		{user.Username, fmt.Sprintf("/api/{version}/users/%s/liked-posts", user.Username), "GET"}, // Auth to see liked posts
-
-		{user.Username, "/api/{version}/comments/{commentId}", "POST"}, // Auth to create comments
-		{user.Username, "/api/{version}/comments/{commentId}/vote", "PUT"}, // Auth to liked/dislike comments
-		{user.Username, "/api/{version}/comments/{commentId}/vote", "DELETE"}, // Auth to liked/dislike comments
-		{user.Username, fmt.Sprintf("/api/{version}/users/%s/comments", user.Username), "GET"}, // Auth to see own comments
-		{user.Username, fmt.Sprintf("/api/{version}/users/%s/liked-comments", user.Username), "GET"}, // Auth to see liked comments
-	}
-
-	err = addAuthPolicies(authenticatedPolicies, router.authEnforcer)
-	if (err != nil) {
-		sendToErrorHandlingMiddleware(err, r)
-		return		
-	}
-
 	requestLogger := getRequestLogger(r)
 	requestLogger.Info("USER-CREATED", "username", user.Username)
+	router.recordAuditEvent(r, user.Username, "USER-CREATED", "user", user.Username, nil)
 
 	authCookie, err := createAuthCookie(user.Username)
 
-	requestLogger.Info("JWT-CREATED", "jwt", authCookie.Value, "username", user.Username)
+	requestLogger.Info("JWT-CREATED", "username", user.Username)
 	requestLogger.Info("USER-AUTHENTICATED", "username", user.Username)
+	router.recordAuditEvent(r, user.Username, "JWT-CREATED", "user", user.Username, nil)
 
 	http.SetCookie(w, authCookie) // Cookie must be set before header is written otherwise cookie will not be set
 	w.WriteHeader(http.StatusCreated)
@@ -1,12 +1,20 @@
 package routes
 
 import (
-	"backend/httperror"
+	"backend/postgres"
+	"strings"
 
 	"github.com/casbin/casbin/v2"
 )
 
 // We define the model here so that it will be included in the built executable
+// Users are assigned roles (e.g. "role:admin") with "g" rules, and inherit the policies of their roles
+// Roles are prefixed with "role:", which usernames cannot start with, so that nobody can take a role's name by signing up
+// Besides roles, policies can have three special subjects, so that no rules are needed per user or per resource:
+//   - "*" matches anyone, including users who are not logged in
+//   - "authenticated" matches any logged in user
+//   - "owner" matches the user who owns the requested resource (see isOwner)
+// The path and method are matched first, so that ownership is only looked up for the policies of the requested endpoint
 var AuthModel = `
 [request_definition]
 r = sub, obj, act
@@ -14,21 +22,68 @@ r = sub, obj, act
 [policy_definition]
 p = sub, obj, act
 
+[role_definition]
+g = _, _
+
 [policy_effect]
 e = some(where (p.eft == allow))
 
 [matchers]
-m = keyMatch(r.sub, p.sub) && keyMatch5(r.obj, p.obj) && r.act == p.act`
+m = keyMatch5(r.obj, p.obj) && r.act == p.act && (p.sub == "*" || (p.sub == "authenticated" && r.sub != "") || (p.sub == "owner" && r.sub != "" && isOwner(r.sub, r.obj)) || (r.sub != "" && p.sub != "authenticated" && p.sub != "owner" && g(r.sub, p.sub)))`
+
+const (
+	rolePrefix    = "role:"
+	adminRole     = "role:admin"
+	moderatorRole = "role:moderator"
+)
+
+// The subjects of policies that are not roles. See AuthModel
+var specialPolicySubjects = []string{"*", "authenticated", "owner"}
+
+// Usernames that could be mistaken for a role or a special subject
+var reservedUsernames = []string{"*", "admin", "moderator", "authenticated", "owner"}
 
-func addAuthPolicies(policies [][]string, e casbin.IEnforcer) error {
-	_, err := e.AddPoliciesEx(policies) // Adds the policies to RAM for quick access
-	if err != nil {
-		return httperror.NewInternalServerError(err)
+// Checks whether the username is reserved (case-insensitively) or starts with the role prefix
+func isReservedUsername(username string) bool {
+	for _, reservedUsername := range reservedUsernames {
+		if strings.EqualFold(username, reservedUsername) {
+			return true
+		}
 	}
-	err = e.SavePolicy() // Saves the policies to the DB for persistence
-	if err != nil {
-		return httperror.NewInternalServerError(err)
+
+	return strings.HasPrefix(strings.ToLower(username), rolePrefix)
+}
+
+// Registers the functions used by the matcher of AuthModel
+func registerAuthFunctions(e casbin.IEnforcer, postgresStore *postgres.PostgresStore) {
+	e.AddFunction("isOwner", func(args ...any) (any, error) {
+		username, _ := args[0].(string)
+		path, _ := args[1].(string)
+		return isOwner(postgresStore, username, path)
+	})
+}
+
+// Checks whether the user owns the resource at the path:
+//   - /api/{version}/users/{username}/... is owned by the user
+//   - /api/{version}/posts/{postId}/... is owned by the author of the post
+//   - /api/{version}/comments/{commentId}/... is owned by the author of the comment
+func isOwner(postgresStore *postgres.PostgresStore, username string, path string) (bool, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if username == "" || len(segments) < 4 || segments[0] != "api" {
+		return false, nil
 	}
 
-	return nil
-}
\ No newline at end of file
+	resourceType, resourceId := segments[2], segments[3]
+	switch resourceType {
+	case "users":
+		return resourceId == username, nil
+	case "posts":
+		author, err := postgresStore.GetPostAuthor(resourceId)
+		return author == username, err
+	case "comments":
+		author, err := postgresStore.GetCommentAuthor(resourceId)
+		return author == username, err
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"sync"
+	"time"
+)
+
+// A concurrency-safe in-memory cache whose entries expire after a fixed time-to-live
+// Expired entries are swept whenever the cache grows past maxEntries, so that the cache cannot grow indefinitely
+type ttlCache[V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]ttlCacheEntry[V]
+}
+
+type ttlCacheEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration, maxEntries int) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]ttlCacheEntry[V]{},
+	}
+}
+
+// Returns the cached value and true, or the zero value and false if there is no unexpired value for the key
+func (cache *ttlCache[V]) Get(key string) (V, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry, ok := cache.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		var zero V
+		return zero, false
+	}
+
+	return entry.value, true
+}
+
+func (cache *ttlCache[V]) Set(key string, value V) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := time.Now()
+	if len(cache.entries) >= cache.maxEntries {
+		for entryKey, entry := range cache.entries {
+			if now.After(entry.expiresAt) {
+				delete(cache.entries, entryKey)
+			}
+		}
+	}
+
+	// If every entry is still fresh, the cache is cleared rather than evicting entries one by one
+	if len(cache.entries) >= cache.maxEntries {
+		cache.entries = map[string]ttlCacheEntry[V]{}
+	}
+
+	cache.entries[key] = ttlCacheEntry[V]{
+		value:     value,
+		expiresAt: now.Add(cache.ttl),
+	}
+}
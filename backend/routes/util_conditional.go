@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"backend/httperror"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Computes a strong ETag from a hash of the response body
+func computeETag(body []byte) string {
+	hash := sha256.Sum256(body)
+	return fmt.Sprintf(`"%s"`, base64.RawURLEncoding.EncodeToString(hash[:16]))
+}
+
+// Checks whether the client's cached copy is still fresh, according to the If-None-Match header,
+// or the If-Modified-Since header if there is no If-None-Match header (lastModified is optional)
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			// ETags are compared weakly, as required for If-None-Match
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" && !lastModified.IsZero() {
+		modifiedSince, err := http.ParseTime(ifModifiedSince)
+		if err != nil {
+			return false
+		}
+		// HTTP dates only have a precision of seconds
+		return !lastModified.Truncate(time.Second).After(modifiedSince)
+	}
+
+	return false
+}
+
+// Writes the response body with an ETag (and a Last-Modified header if lastModified is not zero),
+// unless the client's cached copy is still fresh, in which case only 304 Not Modified is sent
+func writeWithConditionalGet(w http.ResponseWriter, r *http.Request, contentType string, body []byte, lastModified time.Time) {
+	writeWithETag(w, r, contentType, body, computeETag(body), lastModified)
+}
+
+func writeWithETag(w http.ResponseWriter, r *http.Request, contentType string, body []byte, etag string, lastModified time.Time) {
+	w.Header().Set("ETag", etag)
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if isNotModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("content-type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// Writes the value as JSON with an ETag, or only 304 Not Modified if the client's cached copy is still fresh
+// Caches must always revalidate the response. Responses to logged-in users are viewer-specific
+// (e.g. they include the user's votes and bookmarks), so only the user's own browser may cache them
+func writeJSONWithConditionalGet(w http.ResponseWriter, r *http.Request, value any) {
+	writeJSONWithVersionedConditionalGet(w, r, value, 0)
+}
+
+// Same as writeJSONWithConditionalGet, but the ETag of a versioned resource (e.g. a post) is prefixed with its version,
+// so that the ETag can be sent back in the If-Match header of an update. A version of 0 means the value is not versioned
+func writeJSONWithVersionedConditionalGet(w http.ResponseWriter, r *http.Request, value any, version int) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		sendToErrorHandlingMiddleware(httperror.NewInternalServerError(err), r)
+		return
+	}
+	body = append(body, '\n')
+
+	user := getAuthenticatedUser(r)
+	if user.Username == "" {
+		w.Header().Set("Cache-Control", "public, no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	w.Header().Add("Vary", "Cookie") // The response depends on the user's auth cookie
+
+	etag := computeETag(body)
+	if version > 0 {
+		// e.g. "3.<hash>". The hash is kept so that changes to the likes and the user's vote are not cached
+		etag = fmt.Sprintf(`"%d.%s`, version, strings.TrimPrefix(etag, `"`))
+	}
+	writeWithETag(w, r, "application/json", body, etag, time.Time{})
+}
+
+// The ETag of a version, as sent after an update
+func versionETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// Gets the version that an update is based on from the If-Match header, which is required
+// The header can hold either a version ETag (e.g. "3") or the ETag of a versioned GET response (e.g. "3.<hash>")
+// "*" matches any version, in which case 0 is returned
+func getIfMatchVersion(r *http.Request) (int, error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		return 0, ErrPreconditionRequired
+	}
+	if ifMatch == "*" {
+		return 0, nil
+	}
+
+	// Weak ETags are accepted as the version is only compared for equality
+	etag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	versionPart, _, _ := strings.Cut(etag, ".")
+	version, err := strconv.Atoi(versionPart)
+	if err != nil || version < 1 {
+		return 0, NewInputValidationError(map[string]string{
+			"If-Match": `The If-Match header must be the ETag of the latest version (e.g. "3")`,
+		})
+	}
+
+	return version, nil
+}
@@ -1,9 +1,11 @@
 package routes
 
 import (
+	"fmt"
 	"net/http"
 
 	"backend/httperror"
+	"backend/postgres"
 )
 
 var Err404NotFound = &httperror.Error{
@@ -18,6 +20,12 @@ var ErrInvalidJSON = &httperror.Error{
 	Code:    "INVALID-JSON-ERROR",
 }
 
+var ErrPreconditionRequired = &httperror.Error{
+	Status:  http.StatusPreconditionRequired,
+	Message: "The If-Match header is required to prevent overwriting changes made by others",
+	Code:    "PRECONDITION-REQUIRED-ERROR",
+}
+
 var ErrFileTooBig = &httperror.Error{
 	Status: 400,
 	Message: "Uploaded file has exceeded the size limit",
@@ -36,6 +44,50 @@ var ErrUserUnauthorised = &httperror.Error{
 	Code:    "USER-UNAUTHORISED",
 }
 
+// The message tells the user why they were banned and until when
+func NewUserBannedError(ban postgres.UserBan) *httperror.Error {
+	until := "permanently"
+	if ban.ExpiresAt != nil {
+		until = fmt.Sprintf("until %s", *ban.ExpiresAt)
+	}
+
+	return &httperror.Error{
+		Status:  http.StatusForbidden,
+		Message: fmt.Sprintf("You have been banned %s for the following reason: %s", until, ban.Reason),
+		Code:    "USER-BANNED-ERROR",
+	}
+}
+
+var ErrSelfBlock = &httperror.Error{
+	Status:  http.StatusBadRequest,
+	Message: "You cannot block yourself",
+	Code:    "SELF-BLOCK-ERROR",
+}
+
+var ErrSelfBan = &httperror.Error{
+	Status:  http.StatusBadRequest,
+	Message: "You cannot ban yourself",
+	Code:    "SELF-BAN-ERROR",
+}
+
+var ErrModeratorBan = &httperror.Error{
+	Status:  http.StatusForbidden,
+	Message: "Moderators and admins cannot be banned. Remove their role first",
+	Code:    "MODERATOR-BAN-ERROR",
+}
+
+var ErrSelfFollow = &httperror.Error{
+	Status:  http.StatusBadRequest,
+	Message: "You cannot follow yourself",
+	Code:    "SELF-FOLLOW-ERROR",
+}
+
+var ErrPolicyAlreadyExists = &httperror.Error{
+	Status:  http.StatusConflict,
+	Message: "The policy already exists",
+	Code:    "POLICY-ALREADY-EXISTS-ERROR",
+}
+
 var ErrInvalidSupervisor = &httperror.Error{
 	Status:  http.StatusBadRequest,
 	Message: "You have provided an invalid supervisor",
@@ -0,0 +1,61 @@
+package routes
+
+import (
+	"backend/postgres"
+	"net/http"
+	"strconv"
+)
+
+// The filters shared by the post and comment listings. See postgres.ListingFilter
+type listingFilterInput struct {
+	CreatedAfter  string `validate:"omitempty,isIsoDate" name:"created after date"`
+	CreatedBefore string `validate:"omitempty,isIsoDate" name:"created before date"`
+	MinScore      *int   `name:"minimum score"`
+	HasComments   string `validate:"omitempty,boolean" name:"has comments"`
+	Unanswered    string `validate:"omitempty,boolean" name:"unanswered"`
+	UpdatedSince  string `validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00" name:"updated since"`
+}
+
+// Parses the "createdAfter", "createdBefore", "minScore", "hasComments", "unanswered" and "updatedSince" query params
+// The returned values must still be validated with the validation tags of listingFilterInput
+func getListingFilterParams(r *http.Request) (listingFilterInput, error) {
+	input := listingFilterInput{
+		CreatedAfter:  r.URL.Query().Get("createdAfter"),
+		CreatedBefore: r.URL.Query().Get("createdBefore"),
+		HasComments:   r.URL.Query().Get("hasComments"),
+		Unanswered:    r.URL.Query().Get("unanswered"),
+		UpdatedSince:  r.URL.Query().Get("updatedSince"),
+	}
+
+	if minScoreParam := r.URL.Query().Get("minScore"); minScoreParam != "" {
+		minScore, err := strconv.Atoi(minScoreParam)
+		if err != nil {
+			return listingFilterInput{}, NewInputValidationError(map[string]string{"minScore": "The minimum score must be a whole number"})
+		}
+		input.MinScore = &minScore
+	}
+
+	return input, nil
+}
+
+// Converts the validated input into the filter used by the DB queries
+func (input listingFilterInput) toListingFilter() postgres.ListingFilter {
+	return postgres.ListingFilter{
+		CreatedAfter:  input.CreatedAfter,
+		CreatedBefore: input.CreatedBefore,
+		MinScore:      input.MinScore,
+		HasComments:   parseOptionalBool(input.HasComments),
+		Unanswered:    parseOptionalBool(input.Unanswered),
+		UpdatedSince:  input.UpdatedSince,
+	}
+}
+
+// Returns nil if the value is empty. The value must already be validated with the "boolean" validation tag
+func parseOptionalBool(value string) *bool {
+	if value == "" {
+		return nil
+	}
+
+	parsedValue, _ := strconv.ParseBool(value)
+	return &parsedValue
+}
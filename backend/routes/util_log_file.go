@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// A log file that is rotated once it reaches its maximum size
+// The current file is renamed to <path>.1, the previous <path>.1 to <path>.2 and so on,
+// and only the newest maxBackups rotated files are kept
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// Opens the log file, appending to it if it already exists
+func NewRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rotatingFile := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+
+	err := rotatingFile.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return rotatingFile, nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// The file is missing if it could not be reopened after the last rotation, so it is reopened first
+	if f.file == nil {
+		err := f.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// A log line is never split across files, so a line longer than the maximum size gets a file of its own
+	// If the rotation fails but the file could be reopened, the line is still written
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		err := f.rotate()
+		if err != nil && f.file == nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Close()
+}
+
+func (f *RotatingFile) open() error {
+	f.file = nil
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+
+	return nil
+}
+
+// The file is reopened even if it cannot be closed or the rotated files cannot be shifted along,
+// so that logging carries on
+func (f *RotatingFile) rotate() error {
+	closeErr := f.file.Close()
+	f.file = nil
+
+	shiftErr := f.shiftBackups()
+
+	err := f.open()
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(closeErr, shiftErr)
+}
+
+// Shifts the rotated files along, which overwrites the oldest one
+// Missing files are skipped, as there are fewer of them until the log has been rotated maxBackups times
+func (f *RotatingFile) shiftBackups() error {
+	if f.maxBackups <= 0 {
+		return os.Truncate(f.path, 0)
+	}
+
+	for i := f.maxBackups - 1; i >= 1; i -= 1 {
+		err := os.Rename(backupPath(f.path, i), backupPath(f.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return os.Rename(f.path, backupPath(f.path, 1))
+}
+
+func backupPath(path string, index int) string {
+	return fmt.Sprintf("%s.%d", path, index)
+}
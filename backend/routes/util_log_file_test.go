@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readTestLogFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("could not read %s: %s", path, err)
+	}
+	return string(content)
+}
+
+func TestRotatingFileRotatesAtMaxSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backend.log")
+	logFile, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("could not open the log file: %s", err)
+	}
+	defer logFile.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := logFile.Write([]byte(line)); err != nil {
+			t.Fatalf("could not write %q: %s", line, err)
+		}
+	}
+
+	if content := readTestLogFile(t, path); content != "fourth\n" {
+		t.Errorf("the log file has %q, want the newest line", content)
+	}
+	if content := readTestLogFile(t, backupPath(path, 1)); content != "third\n" {
+		t.Errorf("the first backup has %q, want the previous line", content)
+	}
+	if content := readTestLogFile(t, backupPath(path, 2)); content != "second\n" {
+		t.Errorf("the second backup has %q, want the line before", content)
+	}
+	if _, err := os.Stat(backupPath(path, 3)); !os.IsNotExist(err) {
+		t.Error("more backups than maxBackups were kept")
+	}
+}
+
+func TestRotatingFileReopensWhenCloseFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backend.log")
+	logFile, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatalf("could not open the log file: %s", err)
+	}
+	defer logFile.Close()
+
+	if _, err := logFile.Write([]byte("first\n")); err != nil {
+		t.Fatalf("could not write the first line: %s", err)
+	}
+
+	// Closing the file behind the RotatingFile's back makes the rotation's Close fail
+	logFile.file.Close()
+
+	n, err := logFile.Write([]byte("second\n"))
+	if n != len("second\n") {
+		t.Fatalf("wrote %d bytes after the failed close (error: %v), want the whole line", n, err)
+	}
+	if content := readTestLogFile(t, path); content != "second\n" {
+		t.Errorf("the reopened log file has %q, want the line written after the failed close", content)
+	}
+	if content := readTestLogFile(t, backupPath(path, 1)); content != "first\n" {
+		t.Errorf("the backup has %q, want the line written before the failed close", content)
+	}
+}
@@ -2,20 +2,30 @@ package routes
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
 )
 
 // Embed the slog.Logger struct to inherit all its methods & fields
+// The minimum level is shared by the root logger and every logger derived from it, so it can be changed at runtime
 type Logger struct {
 	*slog.Logger
+	level *slog.LevelVar
 }
 
+// Logged right before the application exits. slog has no such level, so it is named by nameFatalLevel
+const LevelFatal = slog.Level(12)
+
 // Overwrite the below methods in order to implement my preferred versions
 func (l Logger) With(args ...any) *Logger {
-	return &Logger{l.Logger.With(args...)}
+	return &Logger{l.Logger.With(args...), l.level}
 }
 
 func (l Logger) Info(msg string, args ...any) {
@@ -35,16 +45,171 @@ func (l Logger) Debug(msg string, args ...any) {
 }
 
 func (l Logger) Fatal(msg string, args ...any) {
-	l.Logger.Log(context.Background(), 12, msg, slog.Group("details", args...))
+	l.Logger.Log(context.Background(), LevelFatal, msg, slog.Group("details", args...))
 	os.Exit(1)
 }
 
+// Gets the minimum level of the logs that are written
+func (l Logger) Level() slog.Level {
+	return l.level.Level()
+}
+
+// Changes the minimum level of the logs that are written, for this logger and every logger that shares its root
+func (l Logger) SetLevel(level slog.Level) {
+	l.level.Set(level)
+}
+
+// Parses "debug", "info", "warn", "error" or "fatal" (case-insensitively). Offsets such as "info+2" are allowed too
+func ParseLogLevel(name string) (slog.Level, error) {
+	if strings.EqualFold(name, "fatal") {
+		return LevelFatal, nil
+	}
+
+	var level slog.Level
+	err := level.UnmarshalText([]byte(name))
+	if err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+
+	return level, nil
+}
+
+// Returns the name of the level, like slog.Level.String but with the FATAL level named
+func logLevelName(level slog.Level) string {
+	if level == LevelFatal {
+		return "FATAL"
+	}
+
+	return level.String()
+}
+
+// Writes the FATAL level as "FATAL" instead of "ERROR+4"
+func nameFatalLevel(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := attr.Value.Any().(slog.Level); ok {
+			attr.Value = slog.StringValue(logLevelName(level))
+		}
+	}
+
+	return attr
+}
+
 const serviceName = "backend"
 
+// Attributes whose keys contain any of these words (case-insensitively) are never written to the logs
+var redactedKeys = []string{"jwt", "password", "token", "cookie", "secret"}
+
+const redactedValue = "[REDACTED]"
+
+// A slog.Handler that masks secrets before passing records on to the next handler
+// Secrets are the values of attributes with a redacted key (including in groups) and string values that look like JWTs
+// If a fingerprint key is set, a masked value is replaced by a short HMAC of it instead, so that log lines about
+// the same token can be correlated without revealing it
+type redactingHandler struct {
+	next           slog.Handler
+	fingerprintKey []byte
+}
+
+func newRedactingHandler(next slog.Handler, fingerprintKey []byte) *redactingHandler {
+	return &redactingHandler{next: next, fingerprintKey: fingerprintKey}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	// Records share their attributes with copies, so a new record is built instead of modifying this one
+	redactedRecord := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redactedRecord.AddAttrs(h.redactAttr(attr))
+		return true
+	})
+
+	return h.next.Handle(ctx, redactedRecord)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redactedAttrs = append(redactedAttrs, h.redactAttr(attr))
+	}
+
+	return newRedactingHandler(h.next.WithAttrs(redactedAttrs), h.fingerprintKey)
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return newRedactingHandler(h.next.WithGroup(name), h.fingerprintKey)
+}
+
+func (h *redactingHandler) redactAttr(attr slog.Attr) slog.Attr {
+	// Resolve LogValuers first so that the secrets they return are masked too
+	attr.Value = attr.Value.Resolve()
+
+	if attr.Value.Kind() == slog.KindGroup {
+		groupAttrs := attr.Value.Group()
+		redactedAttrs := make([]slog.Attr, 0, len(groupAttrs))
+		for _, groupAttr := range groupAttrs {
+			redactedAttrs = append(redactedAttrs, h.redactAttr(groupAttr))
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redactedAttrs...)}
+	}
+
+	if isRedactedKey(attr.Key) || (attr.Value.Kind() == slog.KindString && looksLikeJWT(attr.Value.String())) {
+		return slog.String(attr.Key, h.mask(attr.Value.String()))
+	}
+
+	return attr
+}
+
+func (h *redactingHandler) mask(value string) string {
+	if len(h.fingerprintKey) == 0 || value == "" {
+		return redactedValue
+	}
+
+	mac := hmac.New(sha256.New, h.fingerprintKey)
+	mac.Write([]byte(value))
+	return "[REDACTED:" + hex.EncodeToString(mac.Sum(nil))[:16] + "]"
+}
+
+func isRedactedKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, redactedKey := range redactedKeys {
+		if strings.Contains(key, redactedKey) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// JWTs are three base64url segments, and the first one is always a JSON object (i.e. "eyJ" once encoded)
+func looksLikeJWT(value string) bool {
+	return strings.HasPrefix(value, "eyJ") && strings.Count(value, ".") == 2
+}
+
+// Format is either "json" or "text"
+// Secrets are masked in every log line. If FingerprintKey is not empty, they are replaced by fingerprints instead
+type LoggerOptions struct {
+	Level          slog.Level
+	Format         string
+	FingerprintKey []byte
+}
+
 // Returns a root logger with the chosen output medium
-func NewRootLogger(w io.Writer) *Logger {
-	// Instantiate logger with JSON format & output medium
-	rootLogger := slog.New(slog.NewJSONHandler(w, nil))
+func NewRootLogger(w io.Writer, options LoggerOptions) *Logger {
+	level := &slog.LevelVar{}
+	level.Set(options.Level)
+	handlerOptions := &slog.HandlerOptions{Level: level, ReplaceAttr: nameFatalLevel}
+
+	// Instantiate logger with the chosen format & output medium
+	var handler slog.Handler
+	if options.Format == "text" {
+		handler = slog.NewTextHandler(w, handlerOptions)
+	} else {
+		handler = slog.NewJSONHandler(w, handlerOptions)
+	}
+	rootLogger := slog.New(newRedactingHandler(handler, options.FingerprintKey))
 
 	// Add metadata that apply to all requests
 	hostName, err := os.Hostname()
@@ -54,5 +219,5 @@ func NewRootLogger(w io.Writer) *Logger {
 
 	rootLogger = rootLogger.With("name", serviceName, "hostname", hostName)
 
-	return &Logger{rootLogger}
+	return &Logger{rootLogger, level}
 }
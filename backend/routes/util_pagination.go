@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Parses the "page" and "pageSize" query params. Pages start from 1
+// If the page size is not provided, defaultPageSize is used instead (a page size of 0 means no pagination)
+// The returned values must still be validated with the "page" and "page size" validation tags
+func getPaginationParams(r *http.Request, defaultPageSize int) (int, int, error) {
+	page := 1
+	pageSize := defaultPageSize
+	validationErrors := map[string]string{}
+
+	if pageParam := r.URL.Query().Get("page"); pageParam != "" {
+		parsedPage, err := strconv.Atoi(pageParam)
+		if err != nil {
+			validationErrors["page"] = "The page must be a whole number"
+		}
+		page = parsedPage
+	}
+
+	if pageSizeParam := r.URL.Query().Get("pageSize"); pageSizeParam != "" {
+		parsedPageSize, err := strconv.Atoi(pageSizeParam)
+		if err != nil {
+			validationErrors["pageSize"] = "The page size must be a whole number"
+		}
+		pageSize = parsedPageSize
+	}
+
+	if len(validationErrors) > 0 {
+		return 0, 0, NewInputValidationError(validationErrors)
+	}
+
+	return page, pageSize, nil
+}
+
+// Converts a page and page size into the limit and offset of a DB query
+func getLimitAndOffset(page int, pageSize int) (int, int) {
+	if pageSize == 0 {
+		return 0, 0
+	}
+
+	return pageSize, (page - 1) * pageSize
+}
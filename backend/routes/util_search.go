@@ -0,0 +1,127 @@
+package routes
+
+import (
+	"backend/postgres"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Parses a search query into its free text and its field qualifiers:
+//   - author:<username>   only match content by the author (can be repeated to match any of the authors)
+//   - tag:<tag>           only match posts with the tag (can be repeated to match all of the tags)
+//   - before:<yyyy-mm-dd> only match content created before the date (excluding the date itself)
+//   - after:<yyyy-mm-dd>  only match content created on or after the date
+//   - is:draft            only match the user's own drafts
+//
+// Qualifier values can be quoted (e.g. tag:"career advice"). Everything else is kept as the free text,
+// which supports "quoted phrases", -excluded words and OR
+// Malformed qualifiers are collated into a single input validation error
+func parseSearchQuery(query string) (postgres.SearchQuery, error) {
+	searchQuery := postgres.SearchQuery{}
+	validationErrors := map[string]string{}
+	textTerms := []string{}
+
+	for _, term := range splitSearchQuery(query) {
+		qualifier, value, found := strings.Cut(term, ":")
+		qualifier = strings.ToLower(qualifier)
+		if !found || !isSearchQualifier(qualifier) {
+			textTerms = append(textTerms, term)
+			continue
+		}
+
+		value = strings.Trim(value, `"`)
+		if strings.Trim(value, " ") == "" {
+			validationErrors[qualifier] = fmt.Sprintf(`The "%s:" qualifier must be followed by a value`, qualifier)
+			continue
+		}
+
+		switch qualifier {
+		case "author":
+			searchQuery.Authors = append(searchQuery.Authors, value)
+		case "tag":
+			searchQuery.Tags = append(searchQuery.Tags, value)
+		case "before", "after":
+			_, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				validationErrors[qualifier] = fmt.Sprintf(`The "%s:" qualifier must be followed by a date in the "yyyy-mm-dd" format`, qualifier)
+			} else if qualifier == "before" {
+				searchQuery.Before = value
+			} else {
+				searchQuery.After = value
+			}
+		case "is":
+			if strings.ToLower(value) == "draft" {
+				searchQuery.IsDraft = true
+			} else {
+				validationErrors[qualifier] = fmt.Sprintf(`"is:%s" is not supported. Use "is:draft" instead`, value)
+			}
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		return postgres.SearchQuery{}, NewInputValidationError(validationErrors)
+	}
+
+	searchQuery.Text = strings.Join(textTerms, " ")
+	return searchQuery, nil
+}
+
+func isSearchQualifier(qualifier string) bool {
+	switch qualifier {
+	case "author", "tag", "before", "after", "is":
+		return true
+	default:
+		return false
+	}
+}
+
+// Splits the query by whitespace, except for whitespace within double quotes
+// Quotes are kept so that quoted phrases still work in the free text
+func splitSearchQuery(query string) []string {
+	terms := []string{}
+	var term strings.Builder
+	inQuotes := false
+
+	for _, c := range query {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			term.WriteRune(c)
+		case unicode.IsSpace(c) && !inQuotes:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(c)
+		}
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+
+	return terms
+}
+
+// Parses the search query of a listing. Searching drafts restricts the listing to drafts,
+// which requires the user to be logged in and the listing to have drafts (comments do not)
+func getSearchQuery(query string, user postgres.User, statuses []string, hasDrafts bool) (postgres.SearchQuery, []string, error) {
+	searchQuery, err := parseSearchQuery(query)
+	if err != nil {
+		return postgres.SearchQuery{}, nil, err
+	}
+
+	if searchQuery.IsDraft {
+		if !hasDrafts {
+			return postgres.SearchQuery{}, nil, NewInputValidationError(map[string]string{"is": `"is:draft" is not supported here, as there are no drafts to search`})
+		}
+		if user.Username == "" {
+			return postgres.SearchQuery{}, nil, NewInputValidationError(map[string]string{"is": "You must be logged in to search your drafts"})
+		}
+		statuses = []string{"Draft"}
+	}
+
+	return searchQuery, statuses, nil
+}
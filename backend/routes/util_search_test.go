@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"backend/httperror"
+	"backend/postgres"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDraftSearchOnlyInListingsWithDrafts(t *testing.T) {
+	user := postgres.User{Username: "Jerry_the_mouse"}
+	statuses := []string{"Published"}
+
+	_, got, err := getSearchQuery("is:draft cheese", user, statuses, true)
+	if err != nil {
+		t.Fatalf("searching the drafts of posts failed: %s", err)
+	}
+	if !slices.Equal(got, []string{"Draft"}) {
+		t.Errorf("searching the drafts of posts lists the statuses %v, want only drafts", got)
+	}
+
+	_, got, err = getSearchQuery("is:draft cheese", user, statuses, false)
+	var httpErr *httperror.Error
+	if !errors.As(err, &httpErr) || httpErr.Code != "INVALID-INPUT-ERROR" || got != nil {
+		t.Errorf("searching the drafts of comments listed the statuses %v with error %v, want an input validation error", got, err)
+	}
+}
@@ -0,0 +1,273 @@
+package routes
+
+import (
+	"backend/postgres"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func (router *Router) handleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := router.postgresStore.GetWebhooks()
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("WEBHOOKS-FETCHED")
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(webhooks)
+}
+
+// The function "getWebhookParams" is an abstraction for handleCreateWebhook and handleUpdateWebhook
+// It is not directly attached to any endpoint
+func (router *Router) getWebhookParams(r *http.Request, secretRequired bool) (*postgres.Webhook, error) {
+	type requestInput struct {
+		Id         string   `validate:"required,notBlank,uuid4" name:"id"`
+		TargetUrl  string   `validate:"required,notBlank,max=2000,url,startswith=https://" name:"target url"`
+		Secret     string   `validate:"omitempty,min=16,max=200" name:"secret"`
+		EventTypes []string `validate:"required,notBlank,dive,oneof=post.published comment.created post.deleted" name:"event types"`
+		Tags       []string `validate:"omitempty,max=20,dive,notBlank,max=30" name:"tags"` // Tags are optional. No tags means all tags
+		Active     *bool    `validate:"required" name:"active"`
+	}
+
+	var input requestInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		return nil, ErrInvalidJSON
+	}
+
+	vars := mux.Vars(r)
+	input.Id = vars["webhookId"]
+
+	//Input validation
+	translator := getTranslator(r)
+	err = validateStruct(router.validate, translator, input)
+	if err != nil {
+		return nil, err
+	}
+	if secretRequired && input.Secret == "" {
+		return nil, NewInputValidationError(map[string]string{"secret": "You must provide a secret"})
+	}
+
+	user := getAuthenticatedUser(r)
+	webhook := postgres.Webhook{
+		Id:         input.Id,
+		TargetUrl:  input.TargetUrl,
+		Secret:     input.Secret,
+		EventTypes: input.EventTypes,
+		Tags:       input.Tags,
+		Active:     *input.Active,
+		CreatedBy:  user.Username,
+	}
+	if webhook.Tags == nil {
+		webhook.Tags = []string{}
+	}
+
+	return &webhook, nil
+}
+
+func (router *Router) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := router.getWebhookParams(r, true)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// If there is no error from getWebhookParams, webhook is guaranteed to be non-nil,
+	// so dereferencing can occur safely
+	err = router.postgresStore.CreateWebhook(*webhook)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("WEBHOOK-CREATED", "webhookId", webhook.Id, "targetUrl", webhook.TargetUrl, "eventTypes", webhook.EventTypes, "tags", webhook.Tags)
+	router.recordAuditEvent(r, webhook.CreatedBy, "WEBHOOK-CREATED", "webhook", webhook.Id,
+		map[string]any{"targetUrl": webhook.TargetUrl, "eventTypes": webhook.EventTypes, "tags": webhook.Tags})
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (router *Router) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := router.getWebhookParams(r, false)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// If there is no error from getWebhookParams, webhook is guaranteed to be non-nil,
+	// so dereferencing can occur safely
+	found, err := router.postgresStore.UpdateWebhook(*webhook)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	if !found {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("WEBHOOK-UPDATED", "webhookId", webhook.Id, "targetUrl", webhook.TargetUrl, "eventTypes", webhook.EventTypes, "tags", webhook.Tags, "active", webhook.Active)
+	router.recordAuditEvent(r, webhook.CreatedBy, "WEBHOOK-UPDATED", "webhook", webhook.Id,
+		map[string]any{"targetUrl": webhook.TargetUrl, "eventTypes": webhook.EventTypes, "tags": webhook.Tags, "active": webhook.Active})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *Router) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		Id string `validate:"required,notBlank,uuid4" name:"id"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		Id: vars["webhookId"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	found, err := router.postgresStore.DeleteWebhook(input.Id)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	if !found {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("WEBHOOK-DELETED", "webhookId", input.Id)
+	router.recordAuditEvent(r, getAuthenticatedUser(r).Username, "WEBHOOK-DELETED", "webhook", input.Id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (router *Router) handleGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		WebhookId string `validate:"required,notBlank,uuid4" name:"webhook id"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		WebhookId: vars["webhookId"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	deliveries, err := router.postgresStore.GetWebhookDeliveries(input.WebhookId)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("WEBHOOK-DELIVERIES-FETCHED", "webhookId", input.WebhookId)
+
+	w.Header().Add("content-type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+func (router *Router) handleRedeliverWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	type requestInput struct {
+		WebhookId  string `validate:"required,notBlank,uuid4" name:"webhook id"`
+		DeliveryId string `validate:"required,notBlank,uuid4" name:"delivery id"`
+	}
+
+	vars := mux.Vars(r)
+	input := requestInput{
+		WebhookId:  vars["webhookId"],
+		DeliveryId: vars["deliveryId"],
+	}
+
+	//Input validation
+	translator := getTranslator(r)
+	err := validateStruct(router.validate, translator, input)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+
+	// Make DB query
+	found, err := router.postgresStore.RedeliverWebhookDelivery(input.WebhookId, input.DeliveryId)
+	if err != nil {
+		sendToErrorHandlingMiddleware(err, r)
+		return
+	}
+	if !found {
+		sendToErrorHandlingMiddleware(Err404NotFound, r)
+		return
+	}
+
+	requestLogger := getRequestLogger(r)
+	requestLogger.Info("WEBHOOK-REDELIVERY-QUEUED", "webhookId", input.WebhookId, "deliveryId", input.DeliveryId)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Returns the event that is added to the webhook outbox together with a change to the post
+// Webhooks filter posts by their tags
+func newPostWebhookEvent(eventType string, post postgres.Post) *postgres.WebhookEvent {
+	return &postgres.WebhookEvent{
+		Type: eventType,
+		Tags: post.Tags,
+		Data: postWebhookData{PostId: post.Id, Title: post.Title, Author: post.Author, Tags: post.Tags},
+	}
+}
+
+type postWebhookData struct {
+	PostId string   `json:"postId"`
+	Title  string   `json:"title"`
+	Author string   `json:"author"`
+	Tags   []string `json:"tags"`
+}
+
+type commentWebhookData struct {
+	CommentId string `json:"commentId"`
+	PostId    string `json:"postId"`
+	Author    string `json:"author"`
+	Body      string `json:"body"`
+	ParentId  string `json:"parentId,omitempty"`
+}
+
+// Returns the event that is added to the webhook outbox together with the comment, or nil if its post is not found
+// Webhooks filter comments by the tags of their post
+func (router *Router) newCommentWebhookEvent(comment postgres.Comment) (*postgres.WebhookEvent, error) {
+	post, err := router.postgresStore.GetPostById("", comment.PostId)
+	if err != nil || post == nil {
+		return nil, err
+	}
+
+	webhookData := commentWebhookData{
+		CommentId: comment.Id, PostId: comment.PostId, Author: comment.Author, Body: comment.Body,
+	}
+	if comment.ParentComment != nil {
+		webhookData.ParentId = comment.ParentComment.Id
+	}
+
+	return &postgres.WebhookEvent{Type: "comment.created", Tags: post.Tags, Data: webhookData}, nil
+}
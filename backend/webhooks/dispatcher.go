@@ -0,0 +1,219 @@
+package webhooks
+
+import (
+	"backend/postgres"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	pollInterval      = 5 * time.Second
+	deliveryBatchSize = 20
+	requestTimeout    = 10 * time.Second
+
+	// The deliveries of a batch are sent one at a time, so they are leased for as long as the whole batch can take
+	deliveryLease = deliveryBatchSize*requestTimeout + time.Minute
+
+	// A delivery is marked as failed after this many unsuccessful attempts
+	maxAttempts = 8
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// The parts of the postgres store that the dispatcher depends on
+type Store interface {
+	ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]postgres.DueWebhookDelivery, error)
+	RecordWebhookDeliveryAttempt(attempt postgres.WebhookDeliveryAttempt, status string, nextAttemptAt time.Time) error
+}
+
+// Sends the deliveries in the webhook outbox to their target urls, retrying failed deliveries with exponential backoff
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	logger *slog.Logger
+}
+
+func NewDispatcher(store Store, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: newClient(isPublicAddress),
+		logger: logger,
+	}
+}
+
+var errAddressNotAllowed = errors.New("the target url resolves to a private, loopback or link-local address")
+
+// Returns a client that only connects to the addresses that isAllowedAddress accepts and does not follow redirects,
+// so that webhooks cannot be used to reach the backend's internal network (e.g. the cloud metadata endpoint)
+// Addresses are checked when connecting, after the host name has been resolved, so a host name cannot resolve to
+// a public address when the webhook is saved and to a private one when it is sent
+func newClient(isAllowedAddress func(ip net.IP) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: requestTimeout,
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isAllowedAddress(ip) {
+				return errAddressNotAllowed
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // A proxy would connect to the target on our behalf, without the address check
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   requestTimeout,
+		Transport: transport,
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Reports whether the address is on the public internet
+func isPublicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// Polls the outbox for due deliveries until the context is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.dispatchDueDeliveries(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchDueDeliveries(ctx context.Context) {
+	deliveries, err := d.store.ClaimDueWebhookDeliveries(deliveryBatchSize, deliveryLease)
+	if err != nil {
+		d.logger.Error("WEBHOOK-DELIVERIES-CLAIM-FAILED", "errorMessage", err.Error())
+		return
+	}
+
+	for _, delivery := range deliveries {
+		d.deliver(ctx, delivery)
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery postgres.DueWebhookDelivery) {
+	startTime := time.Now()
+	responseStatus, err := d.send(ctx, delivery)
+
+	attempt := postgres.WebhookDeliveryAttempt{
+		DeliveryId:     delivery.Id,
+		ResponseStatus: responseStatus,
+		DurationMs:     time.Since(startTime).Milliseconds(),
+	}
+
+	status := "Delivered"
+	nextAttemptAt := time.Now()
+	if err != nil {
+		attempt.ErrorMessage = err.Error()
+
+		attempts := delivery.Attempts + 1
+		if attempts >= maxAttempts {
+			status = "Failed"
+		} else {
+			status = "Pending"
+			nextAttemptAt = nextAttemptAt.Add(Backoff(attempts))
+		}
+	}
+
+	err = d.store.RecordWebhookDeliveryAttempt(attempt, status, nextAttemptAt)
+	if err != nil {
+		d.logger.Error("WEBHOOK-DELIVERY-ATTEMPT-RECORDING-FAILED", "deliveryId", delivery.Id, "errorMessage", err.Error())
+		return
+	}
+
+	d.logger.Info("WEBHOOK-DELIVERY-ATTEMPTED", "deliveryId", delivery.Id, "event", delivery.EventType, "status", status, "responseStatus", responseStatus)
+}
+
+// Posts the signed payload to the target url, which must be https so that the payload and its signature stay private
+// Redirects are not followed, so a redirect counts as an unsuccessful delivery
+// Returns the response status (0 if no response was received) and an error if the delivery was unsuccessful
+func (d *Dispatcher) send(ctx context.Context, delivery postgres.DueWebhookDelivery) (int, error) {
+	targetUrl, err := url.Parse(delivery.TargetUrl)
+	if err != nil {
+		return 0, err
+	}
+	if targetUrl.Scheme != "https" {
+		return 0, fmt.Errorf("the target url must use https, not %q", targetUrl.Scheme)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, targetUrl.String(), bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "nus-confess-it-webhooks")
+	request.Header.Set("X-Webhook-Id", delivery.Id)
+	request.Header.Set("X-Webhook-Event", delivery.EventType)
+	request.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Webhook-Signature", "sha256="+Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024)) // Drain the body so the connection can be reused
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("target responded with status %d", response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+// Computes the hex-encoded HMAC-SHA256 of "<timestamp>.<payload>" with the webhook's secret
+// The timestamp is signed too so that receivers can reject replayed deliveries
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Returns how long to wait before the next attempt, given the number of attempts made so far
+// The wait doubles after every attempt, up to a maximum
+func Backoff(attempts int) time.Duration {
+	backoff := baseBackoff
+	for i := 1; i < attempts; i += 1 {
+		backoff *= 2
+		if backoff >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	return backoff
+}
@@ -0,0 +1,366 @@
+package webhooks
+
+import (
+	"backend/postgres"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// An in-memory Store that leases the due deliveries like the postgres store does, and records the attempts
+// Its clock can be moved forward with elapsed, so that leases can expire without waiting
+type fakeStore struct {
+	mu         sync.Mutex
+	deliveries []postgres.DueWebhookDelivery
+	leases     map[string]time.Time
+	elapsed    time.Duration
+	attempts   []recordedAttempt
+}
+
+type recordedAttempt struct {
+	attempt       postgres.WebhookDeliveryAttempt
+	status        string
+	nextAttemptAt time.Time
+}
+
+func (s *fakeStore) now() time.Time {
+	return time.Now().Add(s.elapsed)
+}
+
+func (s *fakeStore) ClaimDueWebhookDeliveries(limit int, lease time.Duration) ([]postgres.DueWebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.leases == nil {
+		s.leases = map[string]time.Time{}
+	}
+
+	claimed := []postgres.DueWebhookDelivery{}
+	for _, delivery := range s.deliveries {
+		if len(claimed) < limit && !s.leases[delivery.Id].After(s.now()) {
+			s.leases[delivery.Id] = s.now().Add(lease)
+			claimed = append(claimed, delivery)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) advance(duration time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.elapsed += duration
+}
+
+func (s *fakeStore) RecordWebhookDeliveryAttempt(attempt postgres.WebhookDeliveryAttempt, status string, nextAttemptAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts = append(s.attempts, recordedAttempt{attempt, status, nextAttemptAt})
+	s.leases[attempt.DeliveryId] = nextAttemptAt
+	if status != "Pending" {
+		s.leases[attempt.DeliveryId] = time.Now().Add(100 * 365 * 24 * time.Hour) // Never due again
+	}
+	return nil
+}
+
+// Returns a dispatcher that trusts the receiver's certificate and may connect to it, even though it is on loopback
+func newTestDispatcher(store Store, receiver *httptest.Server) *Dispatcher {
+	dispatcher := NewDispatcher(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	dispatcher.client = newClient(func(ip net.IP) bool { return true })
+	dispatcher.client.Transport.(*http.Transport).TLSClientConfig = receiver.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+	return dispatcher
+}
+
+func TestSign(t *testing.T) {
+	secret := "webhook-secret"
+	payload := []byte(`{"event":"post.published"}`)
+	var timestamp int64 = 1700000000
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("1700000000." + string(payload)))
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if signature := Sign(secret, timestamp, payload); signature != expected {
+		t.Errorf("Sign() = %s, want %s", signature, expected)
+	}
+	if Sign(secret, timestamp+1, payload) == expected {
+		t.Error("the signature does not depend on the timestamp")
+	}
+	if Sign("other-secret", timestamp, payload) == expected {
+		t.Error("the signature does not depend on the secret")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, baseBackoff},
+		{2, 2 * baseBackoff},
+		{3, 4 * baseBackoff},
+		{4, 8 * baseBackoff},
+		{20, maxBackoff},
+		{1000, maxBackoff},
+	}
+
+	for _, test := range tests {
+		if backoff := Backoff(test.attempts); backoff != test.expected {
+			t.Errorf("Backoff(%d) = %s, want %s", test.attempts, backoff, test.expected)
+		}
+	}
+}
+
+func TestDeliverySignedAndRecorded(t *testing.T) {
+	secret := "webhook-secret"
+	payload := []byte(`{"event":"comment.created","data":{"commentId":"1"}}`)
+
+	var receivedBody []byte
+	var receivedHeaders http.Header
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = io.ReadAll(r.Body)
+		receivedHeaders = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{deliveries: []postgres.DueWebhookDelivery{{
+		Id: "delivery-1", EventType: "comment.created", Payload: payload, TargetUrl: receiver.URL, Secret: secret,
+	}}}
+	newTestDispatcher(store, receiver).dispatchDueDeliveries(context.Background())
+
+	if string(receivedBody) != string(payload) {
+		t.Fatalf("received body %q, want %q", receivedBody, payload)
+	}
+	if receivedHeaders.Get("X-Webhook-Event") != "comment.created" || receivedHeaders.Get("X-Webhook-Id") != "delivery-1" {
+		t.Errorf("unexpected event headers: %v", receivedHeaders)
+	}
+
+	// Verify the signature the way a receiver would
+	timestamp, err := strconv.ParseInt(receivedHeaders.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid timestamp header: %s", err)
+	}
+	if signature := receivedHeaders.Get("X-Webhook-Signature"); signature != "sha256="+Sign(secret, timestamp, receivedBody) {
+		t.Errorf("invalid signature %s", signature)
+	}
+
+	if len(store.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(store.attempts))
+	}
+	recorded := store.attempts[0]
+	if recorded.status != "Delivered" || recorded.attempt.ResponseStatus != http.StatusNoContent || recorded.attempt.ErrorMessage != "" {
+		t.Errorf("unexpected attempt: %+v", recorded)
+	}
+}
+
+func TestFailedDeliveryRetriedWithBackoff(t *testing.T) {
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{deliveries: []postgres.DueWebhookDelivery{{
+		Id: "delivery-1", EventType: "post.published", Payload: []byte(`{}`), Attempts: 1, TargetUrl: receiver.URL, Secret: "secret",
+	}}}
+	before := time.Now()
+	newTestDispatcher(store, receiver).dispatchDueDeliveries(context.Background())
+
+	if len(store.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(store.attempts))
+	}
+	recorded := store.attempts[0]
+	if recorded.status != "Pending" || recorded.attempt.ResponseStatus != http.StatusInternalServerError || recorded.attempt.ErrorMessage == "" {
+		t.Errorf("unexpected attempt: %+v", recorded)
+	}
+
+	// This was the second attempt, so the next one waits for the second backoff
+	earliest := before.Add(Backoff(2))
+	if recorded.nextAttemptAt.Before(earliest) || recorded.nextAttemptAt.After(time.Now().Add(Backoff(2))) {
+		t.Errorf("next attempt at %s, want about %s", recorded.nextAttemptAt, earliest)
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{deliveries: []postgres.DueWebhookDelivery{{
+		Id: "delivery-1", EventType: "post.deleted", Payload: []byte(`{}`), Attempts: maxAttempts - 1, TargetUrl: receiver.URL, Secret: "secret",
+	}}}
+	newTestDispatcher(store, receiver).dispatchDueDeliveries(context.Background())
+
+	if len(store.attempts) != 1 || store.attempts[0].status != "Failed" {
+		t.Errorf("unexpected attempts: %+v", store.attempts)
+	}
+}
+
+func TestUnreachableTargetRecorded(t *testing.T) {
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	targetUrl := receiver.URL
+	receiver.Close() // Nothing listens on the url anymore
+
+	store := &fakeStore{deliveries: []postgres.DueWebhookDelivery{{
+		Id: "delivery-1", EventType: "post.published", Payload: []byte(`{}`), TargetUrl: targetUrl, Secret: "secret",
+	}}}
+	newTestDispatcher(store, receiver).dispatchDueDeliveries(context.Background())
+
+	if len(store.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(store.attempts))
+	}
+	recorded := store.attempts[0]
+	if recorded.status != "Pending" || recorded.attempt.ResponseStatus != 0 || recorded.attempt.ErrorMessage == "" {
+		t.Errorf("unexpected attempt: %+v", recorded)
+	}
+}
+
+func TestDeliveryInFlightNotClaimedAgain(t *testing.T) {
+	store := &fakeStore{}
+	received := make(chan struct{}, deliveryBatchSize)
+	release := make(chan struct{})
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer receiver.Close()
+
+	// A full batch, so the last delivery is only sent once all the others have taken as long as they can
+	for i := 0; i < deliveryBatchSize; i += 1 {
+		store.deliveries = append(store.deliveries, postgres.DueWebhookDelivery{
+			Id: "delivery-" + strconv.Itoa(i), EventType: "post.published", Payload: []byte(`{}`), TargetUrl: receiver.URL, Secret: "secret",
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		newTestDispatcher(store, receiver).dispatchDueDeliveries(context.Background())
+		close(done)
+	}()
+	<-received
+
+	// Another instance polls once every delivery of the batch could have timed out
+	store.advance(deliveryBatchSize * requestTimeout)
+	if claimed, _ := store.ClaimDueWebhookDeliveries(deliveryBatchSize, deliveryLease); len(claimed) != 0 {
+		t.Errorf("%d deliveries of the batch in flight were claimed again", len(claimed))
+	}
+
+	close(release)
+	<-done
+	if len(store.attempts) != deliveryBatchSize {
+		t.Errorf("recorded %d attempts, want %d", len(store.attempts), deliveryBatchSize)
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, test := range tests {
+		if isPublic := isPublicAddress(net.ParseIP(test.ip)); isPublic != test.expected {
+			t.Errorf("isPublicAddress(%s) = %t, want %t", test.ip, isPublic, test.expected)
+		}
+	}
+}
+
+// Sends a single delivery to the target url with the dispatcher, and returns the recorded attempt
+func dispatchTestDelivery(t *testing.T, dispatcher *Dispatcher, store *fakeStore, targetUrl string) recordedAttempt {
+	store.deliveries = []postgres.DueWebhookDelivery{{
+		Id: "delivery-1", EventType: "post.published", Payload: []byte(`{}`), TargetUrl: targetUrl, Secret: "secret",
+	}}
+	dispatcher.dispatchDueDeliveries(context.Background())
+
+	if len(store.attempts) != 1 {
+		t.Fatalf("recorded %d attempts, want 1", len(store.attempts))
+	}
+	return store.attempts[0]
+}
+
+func TestPrivateTargetsNotContacted(t *testing.T) {
+	contacted := false
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contacted = true
+	}))
+	defer receiver.Close()
+
+	// The production dispatcher, which only connects to public addresses
+	store := &fakeStore{}
+	dispatcher := NewDispatcher(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	dispatcher.client.Transport.(*http.Transport).TLSClientConfig = receiver.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+
+	recorded := dispatchTestDelivery(t, dispatcher, store, receiver.URL)
+	if contacted {
+		t.Error("the dispatcher connected to a loopback address")
+	}
+	if recorded.status != "Pending" || !strings.Contains(recorded.attempt.ErrorMessage, errAddressNotAllowed.Error()) {
+		t.Errorf("unexpected attempt: %+v", recorded)
+	}
+}
+
+func TestHttpTargetsNotContacted(t *testing.T) {
+	contacted := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contacted = true
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{}
+	recorded := dispatchTestDelivery(t, newTestDispatcher(store, receiver), store, receiver.URL)
+	if contacted {
+		t.Error("the payload was sent without https")
+	}
+	if recorded.status != "Pending" || recorded.attempt.ErrorMessage == "" {
+		t.Errorf("unexpected attempt: %+v", recorded)
+	}
+}
+
+func TestRedirectsNotFollowed(t *testing.T) {
+	redirected := false
+	redirectTarget := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer redirectTarget.Close()
+
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, redirectTarget.URL, http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	store := &fakeStore{}
+	recorded := dispatchTestDelivery(t, newTestDispatcher(store, receiver), store, receiver.URL)
+	if redirected {
+		t.Error("the redirect was followed")
+	}
+	if recorded.status != "Pending" || recorded.attempt.ResponseStatus != http.StatusTemporaryRedirect {
+		t.Errorf("unexpected attempt: %+v", recorded)
+	}
+}
@@ -28,6 +28,7 @@ const commentsApi = baseApiSlice.injectEndpoints({
             query: comment => ({
               url: `/comments/${comment.id}`,
               method: 'PUT',
+              headers: { "If-Match": `"${comment.version}"` }, // Rejected if someone else has changed the comment since
               body: comment
             }),
             invalidatesTags: ["Comment"]
@@ -57,6 +57,7 @@ export const CommentEditor = () => {
             parentId: replyTo?.id || "",
             parentAuthor: replyTo?.author || "",
             parentBody: replyTo?.body || "",
+            version: commentToEdit?.version,
         }
         try {
             if (editComment) {
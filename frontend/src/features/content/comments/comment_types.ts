@@ -9,6 +9,7 @@ export interface Comment {
     dislikes: number,
     createdAt: string, 
     updatedAt: string,
+    version: number,
     userVote: "Like" | "Dislike" | ""
 }
 
@@ -18,5 +19,6 @@ export interface NewComment {
     postId: string,
     parentId: string,
     parentAuthor: string,
-    parentBody: string
+    parentBody: string,
+    version?: number, // The version that an update is based on
 }
\ No newline at end of file
@@ -1,5 +1,5 @@
 import { baseApiSlice } from "@/redux/api";
-import { NewPost, Post } from "./post_types";
+import { NewPost, Post, Tag } from "./post_types";
 
 interface GetPostProps {
     query?: string,
@@ -30,6 +30,7 @@ const postsApi = baseApiSlice.injectEndpoints({
             query: post => ({
               url: `/posts/${post.id}`,
               method: 'PUT',
+              headers: { "If-Match": `"${post.version}"` }, // Rejected if someone else has changed the post since
               body: post
             }),
             invalidatesTags: ['Post']
@@ -38,6 +39,7 @@ const postsApi = baseApiSlice.injectEndpoints({
             query: post => ({
               url: `/posts/${post.id}/conversion`,
               method: 'POST',
+              headers: { "If-Match": `"${post.version}"` }, // Rejected if the draft has been changed since, e.g. in another tab
               body: post
             }),
             invalidatesTags: ['Post']
@@ -89,7 +91,9 @@ const postsApi = baseApiSlice.injectEndpoints({
         }),        
 
         getTags: builder.query<string[], void>({
-            query: () => '/tags'
+            query: () => '/tags',
+            // Retired tags can no longer be added to posts, so they are not suggested
+            transformResponse: (tags: Tag[]) => tags.filter(tag => !tag.retired).map(tag => tag.name)
         }),
       })
 })
@@ -9,6 +9,7 @@ export interface Post {
     status: string,
     createdAt: string,
     updatedAt: string,
+    version: number,
     userVote: "Like" | "Dislike" | ""    
 }
 
@@ -18,4 +19,15 @@ export interface NewPost {
     body: string,
     tags: string[],
     status: string,
-}
\ No newline at end of file
+    version?: number, // The version that an update is based on
+}
+
+export interface Tag {
+    name: string,
+    description: string,
+    colour: string,
+    retired: boolean,
+    parent: string,
+    aliases: string[],
+    postCount: number
+}
@@ -56,7 +56,8 @@ export default function EditPost() {
         const updatedPost = {
           ...formState.values,
           id: post.id,
-          status: "Published"
+          status: "Published",
+          version: post.version
         }
 
         if (post.status == "Published") {
@@ -81,7 +82,8 @@ export default function EditPost() {
         const updatedPost = {
           ...formState.values,
           id: post.id,
-          status: "Draft"
+          status: "Draft",
+          version: post.version
         }
         await updatePost(updatedPost).unwrap()
 